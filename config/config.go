@@ -0,0 +1,196 @@
+package config
+
+import (
+	"catselector/export"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Version of the application
+const Version = "1.0.4"
+
+// Ways of opening the exported bundles
+const (
+	OpenAuto   = "auto"   // Terminal editor when there is no graphical session
+	OpenSystem = "system" // Default application of the system
+	OpenEditor = "editor" // $VISUAL or $EDITOR in the terminal
+)
+
+// Clipboard backends
+const (
+	ClipboardAuto   = "auto"   // Providers in the configured or default order
+	ClipboardSystem = "system" // pbcopy, xclip, xsel or wl-copy
+	ClipboardOSC52  = "osc52"  // Escape sequence handled by the terminal
+)
+
+// Config holds the user preferences loaded from the configuration file
+type Config struct {
+	ShowHidden     bool     `json:"show_hidden"`     // Show hidden entries by default
+	HiddenPatterns []string `json:"hidden_patterns"` // Extra name patterns treated as hidden (e.g. "node_modules")
+
+	Sort        export.SortOptions  `json:"sort"`         // Sort mode of the panels
+	ExportOrder export.OrderOptions `json:"export_order"` // Order and grouping of the exported files
+
+	Output export.OutputOptions `json:"output"` // Location and name of the exported files
+	Chunk  export.ChunkOptions  `json:"chunk"`  // Split large bundles in parts
+
+	NonUTF8   string               `json:"non_utf8"`   // Policy for text files that are not UTF-8: "transcode" or "skip"
+	Redaction export.RedactOptions `json:"redaction"`  // Redaction of secrets before exporting
+	FileLimit export.LimitOptions  `json:"file_limit"` // Per-file size limits in exports
+	Minify    export.MinifyOptions `json:"minify"`     // Transforms making the exported content smaller
+
+	LineNumbers export.LineNumberOptions `json:"line_numbers"` // Original line numbers in the exported content
+	Manifest    string                   `json:"manifest"`     // Manifest with checksums: none, section, sidecar or both
+
+	ArchiveFormat string `json:"archive_format"` // Format of the archive export: "tar.gz" or "zip"
+
+	Templates map[string]string `json:"templates"` // Prompt templates wrapping the bundle, by name
+	Commands  map[string]string `json:"commands"`  // Commands receiving the bundle on stdin, by name
+
+	OpenWith string `json:"open_with"` // How bundles are opened: auto, system or editor
+
+	Clipboard      string   `json:"clipboard"`       // Clipboard backend: auto, system or the name of a provider
+	ClipboardOrder []string `json:"clipboard_order"` // Order in which the clipboard providers are tried
+	OSC52Limit     int      `json:"osc52_limit"`     // Size in bytes above which OSC 52 copies show a warning
+
+	WatchDebounce int `json:"watch_debounce_ms"` // Quiet time in milliseconds before a watched bundle is regenerated
+}
+
+// Loaded configuration, read once on first use
+var current *Config
+
+// Default returns the configuration used when there is no configuration file
+func Default() Config {
+	return Config{
+		ShowHidden:     false,
+		HiddenPatterns: []string{},
+		Sort: export.SortOptions{
+			Mode: export.SortName,
+		},
+		ExportOrder: export.OrderOptions{
+			Mode:     export.OrderPath,
+			Priority: []string{},
+		},
+		Output: export.OutputOptions{
+			Dir:         "",
+			Template:    export.DefaultTemplate,
+			Naming:      export.NamingOverwrite,
+			CleanupDays: 7,
+		},
+		NonUTF8: export.EncodingTranscode,
+		Redaction: export.RedactOptions{
+			Enabled:     true,
+			HighEntropy: true,
+			Patterns:    []string{},
+			Deny:        []string{"*.pem", "*.key", "*.p12", "*.pfx", "id_rsa", "id_dsa", "id_ecdsa", "id_ed25519"},
+		},
+		FileLimit: export.LimitOptions{
+			MaxBytes:  0,
+			MaxLines:  0,
+			Strategy:  export.LimitHeadTail,
+			HeadLines: 100,
+			TailLines: 20,
+		},
+		LineNumbers: export.LineNumberOptions{
+			Enabled:   false,
+			Separator: " | ",
+		},
+		Manifest:      export.ManifestNone,
+		ArchiveFormat: export.FormatTarGz,
+		Templates:     map[string]string{},
+		Commands:      map[string]string{},
+		OpenWith:      OpenAuto,
+		Clipboard:     ClipboardAuto,
+		OSC52Limit:    74994, // 100000 bytes once encoded in base64
+		WatchDebounce: 300,
+	}
+}
+
+// Check reports whether a configuration file can be read, nil if it is
+// valid or does not exist
+func Check(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cfg := Default()
+	return json.Unmarshal(data, &cfg)
+}
+
+// Dir returns the directory where the configuration is stored
+func Dir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "catsel")
+}
+
+// Path returns the path of the configuration file
+func Path() string {
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Get returns the current configuration, loading it if necessary
+func Get() *Config {
+	if current == nil {
+		cfg := Load(Path())
+		current = &cfg
+	}
+	return current
+}
+
+// Load reads the configuration from a file, falling back to the defaults
+// for the missing fields or if the file cannot be read
+func Load(path string) Config {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg
+	}
+	// Ignore malformed files and keep the defaults
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Default()
+	}
+	return cfg
+}
+
+// SaveSort writes the sort mode of the current configuration to the
+// configuration file. Only the "sort" key is changed: the rest of the file
+// is kept as written, and a malformed file is left untouched.
+func SaveSort() error {
+	return saveKey("sort", Get().Sort)
+}
+
+// Replace one key of the configuration file
+func saveKey(key string, value any) error {
+	fields := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(Path())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("%s is not valid: %w", Path(), err)
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fields[key] = encoded
+	data, err = json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(Path(), append(data, '\n'), 0644)
+}
@@ -0,0 +1,180 @@
+package content
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding of the content of a file
+type Encoding string
+
+// Detected encodings
+const (
+	UTF8    Encoding = "utf-8"
+	UTF8BOM Encoding = "utf-8-bom"
+	UTF16LE Encoding = "utf-16le"
+	UTF16BE Encoding = "utf-16be"
+	Latin1  Encoding = "latin-1"
+	Binary  Encoding = "binary"
+)
+
+// Number of bytes read to detect the encoding of a file
+const sniffSize = 8000
+
+// IsText checks if the encoding can be shown as text
+func (e Encoding) IsText() bool {
+	return e != Binary
+}
+
+// IsUTF8 checks if the content can be used without transcoding
+func (e Encoding) IsUTF8() bool {
+	return e == UTF8 || e == UTF8BOM
+}
+
+// Sniff detects the encoding from the first bytes of a file
+func Sniff(data []byte) Encoding {
+	if len(data) > sniffSize {
+		data = data[:sniffSize]
+	}
+
+	// Byte order marks
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8BOM
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+	if len(data) == 0 {
+		return UTF8
+	}
+
+	// UTF-16 without BOM: ASCII text has a zero in every other byte
+	evenZeros, oddZeros := 0, 0
+	for i, c := range data {
+		if c == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+	half := len(data) / 2
+	if half > 0 {
+		if oddZeros > half*4/10 && evenZeros == 0 {
+			return UTF16LE
+		}
+		if evenZeros > half*4/10 && oddZeros == 0 {
+			return UTF16BE
+		}
+	}
+	if evenZeros+oddZeros > 0 {
+		return Binary
+	}
+
+	// Control characters other than whitespace are rare in text files
+	control := 0
+	for _, c := range data {
+		if c < 32 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1b {
+			control++
+		}
+	}
+	if float64(control)/float64(len(data)) > 0.3 {
+		return Binary
+	}
+
+	// Ignore a rune cut at the end of the sample
+	if validUTF8Prefix(data) {
+		return UTF8
+	}
+
+	// Latin-1 text rarely uses the C1 control range (0x80-0x9F)
+	c1 := 0
+	for _, c := range data {
+		if c >= 0x80 && c <= 0x9F {
+			c1++
+		}
+	}
+	if float64(c1)/float64(len(data)) > 0.1 {
+		return Binary
+	}
+	return Latin1
+}
+
+// Check if the data is valid UTF-8, allowing an incomplete rune at the end
+func validUTF8Prefix(data []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if utf8.Valid(data) {
+			return true
+		}
+		r, _ := utf8.DecodeLastRune(data)
+		if r != utf8.RuneError {
+			return false
+		}
+		data = data[:len(data)-1]
+	}
+	return utf8.Valid(data)
+}
+
+// Decode converts content in the given encoding to UTF-8
+func Decode(data []byte, encoding Encoding) string {
+	switch encoding {
+	case UTF8BOM:
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	case UTF16LE, UTF16BE:
+		if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if encoding == UTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units))
+	case Latin1:
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+// SniffFile detects the encoding of a file reading only its first bytes
+func SniffFile(path string) (Encoding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return Sniff(buf[:n]), nil
+}
+
+// ReadFile reads a file and returns its content as UTF-8 with its
+// original encoding. The content of binary files is not returned.
+func ReadFile(path string) (string, Encoding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	encoding := Sniff(data)
+	if encoding == Binary {
+		return "", encoding, nil
+	}
+	return Decode(data, encoding), encoding, nil
+}
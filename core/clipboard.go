@@ -0,0 +1,270 @@
+package core
+
+import (
+	"catselector/config"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	"golang.org/x/term"
+)
+
+// ClipboardProvider copies text to a clipboard
+type ClipboardProvider interface {
+	// Name of the provider, as used in the configuration
+	Name() string
+	// Available returns nil if the provider can be used, or the reason why not
+	Available() error
+	// Copy copies the text and returns a note for the user (e.g. a warning)
+	Copy(text string) (string, error)
+}
+
+// ClipboardProviders lists all the providers, in the default order
+func ClipboardProviders() []ClipboardProvider {
+	return []ClipboardProvider{
+		commandProvider{name: "pbcopy", args: []string{"pbcopy"}, goos: "darwin"},
+		commandProvider{name: "wl-copy", args: []string{"wl-copy"}, env: "WAYLAND_DISPLAY"},
+		commandProvider{name: "xclip", args: []string{"xclip", "-selection", "clipboard"}, env: "DISPLAY"},
+		commandProvider{name: "xsel", args: []string{"xsel", "--clipboard", "--input"}, env: "DISPLAY"},
+		tmuxProvider{},
+		osc52Provider{},
+		fileProvider{},
+	}
+}
+
+// OrderedClipboardProviders returns the providers in the order they are
+// tried: a forced backend, the configured order, or the default one (with
+// OSC 52 first over SSH, where the system tools copy on the remote machine,
+// and the file always last, after every real clipboard)
+func OrderedClipboardProviders() []ClipboardProvider {
+	all := ClipboardProviders()
+	byName := make(map[string]ClipboardProvider)
+	for _, p := range all {
+		byName[p.Name()] = p
+	}
+
+	cfg := config.Get()
+	switch backend := cfg.Clipboard; backend {
+	case config.ClipboardAuto, "":
+	case config.ClipboardSystem:
+		return all[:4]
+	default:
+		if p, ok := byName[backend]; ok {
+			return []ClipboardProvider{p}
+		}
+	}
+
+	var ordered []ClipboardProvider
+	if len(cfg.ClipboardOrder) > 0 {
+		for _, name := range cfg.ClipboardOrder {
+			if p, ok := byName[name]; ok {
+				ordered = append(ordered, p)
+			}
+		}
+		return ordered
+	}
+	overSSH := os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+	if overSSH {
+		ordered = append(ordered, byName[config.ClipboardOSC52])
+	}
+	for _, p := range all {
+		if !overSSH || p.Name() != config.ClipboardOSC52 {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+// CopyToClipboard copies text with the first available provider that
+// succeeds. It returns a note for the user, or an error explaining why
+// every provider failed.
+func CopyToClipboard(text string) (string, error) {
+	var failures []string
+	for _, p := range OrderedClipboardProviders() {
+		if err := p.Available(); err != nil {
+			failures = append(failures, p.Name()+": "+err.Error())
+			continue
+		}
+		note, err := p.Copy(text)
+		if err == nil {
+			return note, nil
+		}
+		failures = append(failures, p.Name()+": "+err.Error())
+	}
+	if len(failures) == 0 {
+		return "", errors.New("no clipboard provider configured")
+	}
+	return "", errors.New(strings.Join(failures, "; "))
+}
+
+// Provider running a command that reads the text on its stdin
+type commandProvider struct {
+	name string   // Name of the provider
+	args []string // Command and arguments
+	goos string   // Only operating system where it works, if any
+	env  string   // Environment variable of the display it needs, if any
+}
+
+func (p commandProvider) Name() string {
+	return p.name
+}
+
+func (p commandProvider) Available() error {
+	if p.goos != "" && runtime.GOOS != p.goos {
+		return fmt.Errorf("only on %s", p.goos)
+	}
+	if _, err := exec.LookPath(p.args[0]); err != nil {
+		return errors.New("not installed")
+	}
+	if p.env != "" && os.Getenv(p.env) == "" {
+		return fmt.Errorf("%s is not set", p.env)
+	}
+	return nil
+}
+
+func (p commandProvider) Copy(text string) (string, error) {
+	return "", runWithInput(text, p.args...)
+}
+
+// Provider loading the text in a tmux buffer, which tmux also copies to
+// the clipboard of the outer terminal when set-clipboard is enabled
+type tmuxProvider struct{}
+
+func (tmuxProvider) Name() string {
+	return "tmux"
+}
+
+func (tmuxProvider) Available() error {
+	if os.Getenv("TMUX") == "" {
+		return errors.New("not inside tmux")
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return errors.New("not installed")
+	}
+	return nil
+}
+
+func (tmuxProvider) Copy(text string) (string, error) {
+	if runWithInput(text, "tmux", "load-buffer", "-w", "-") == nil {
+		return "", nil
+	}
+	// Versions before 3.2 have no -w, the text is only in the tmux buffer
+	if err := runWithInput(text, "tmux", "load-buffer", "-"); err != nil {
+		return "", err
+	}
+	return " (in the tmux buffer, paste with prefix + ])", nil
+}
+
+// Provider sending an OSC 52 escape sequence to the terminal, which copies
+// the text to the local clipboard even over SSH
+type osc52Provider struct{}
+
+func (osc52Provider) Name() string {
+	return config.ClipboardOSC52
+}
+
+func (osc52Provider) Available() error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return errors.New("no terminal")
+	}
+	tty.Close()
+	if os.Getenv("TERM") == "dumb" {
+		return errors.New("TERM is dumb")
+	}
+	return nil
+}
+
+func (osc52Provider) Copy(text string) (string, error) {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+
+	// Inside the interface the sequence goes through the program output,
+	// between two frames; otherwise straight to the terminal
+	if programOutput != nil && term.IsTerminal(int(programOutput.Fd())) {
+		if _, err := seq.WriteTo(programOutput); err != nil {
+			return "", err
+		}
+	} else {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return "", err
+		}
+		defer tty.Close()
+		if _, err := seq.WriteTo(tty); err != nil {
+			return "", err
+		}
+	}
+
+	// Terminals silently drop or cut sequences above their limit, and
+	// nothing comes back to tell whether the copy worked
+	if limit := config.Get().OSC52Limit; limit > 0 && len(text) > limit {
+		return fmt.Sprintf(" (OSC 52, unverified: %d KB may exceed the terminal limit of %d KB)", len(text)/1024, limit/1024), nil
+	}
+	return " (OSC 52, unverified)", nil
+}
+
+// Provider writing the text to a file, the last resort when there is no clipboard
+type fileProvider struct{}
+
+func (fileProvider) Name() string {
+	return "file"
+}
+
+func (fileProvider) Available() error {
+	return nil
+}
+
+func (fileProvider) Copy(text string) (string, error) {
+	path := ClipboardFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	return " (no clipboard, written to " + path + ")", nil
+}
+
+// ClipboardFile returns the file used when there is no clipboard
+func ClipboardFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "catsel", "clipboard.txt")
+}
+
+// Run a command with the text on its stdin, including its error output
+// in the returned error. The error output goes to a file and not to a pipe:
+// xclip and wl-copy leave a child keeping the selection, which inherits it,
+// and waiting for a pipe would wait for that child to exit.
+func runWithInput(text string, args ...string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	stderr, err := os.CreateTemp("", "catsel-stderr-*")
+	if err == nil {
+		defer os.Remove(stderr.Name())
+		defer stderr.Close()
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Run(); err != nil {
+		if stderr != nil {
+			data, _ := os.ReadFile(stderr.Name())
+			if msg := strings.TrimSpace(string(data)); msg != "" {
+				return fmt.Errorf("%v: %s", err, msg)
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bytes"
+	"catselector/config"
+	"catselector/export"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PendingCommand is a command waiting to receive a bundle on its stdin
+type PendingCommand struct {
+	Name  string // Name of the command in the configuration
+	Line  string // Command line, run by the shell
+	Dir   string // Working directory
+	Input []byte // Content of the bundle
+}
+
+// CommandOutput is the result of a command
+type CommandOutput struct {
+	Name     string        // Name of the command
+	Output   string        // Standard output and error
+	Err      error         // Error running the command or exit status
+	Duration time.Duration // Time the command took
+}
+
+// Escape sequences removed from the output before showing it: CSI
+// sequences, strings (OSC, DCS...) up to their terminator and the other
+// two-byte sequences
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]|\x1b[P\\]X^_][^\x07\x1b\n]*(?:\x07|\x1b\\\\)?|\x1b[@-Z\\\\-_]")
+
+// Open the menu of the commands that can receive the bundle
+func StartCommandMode(s *Selector) {
+	var names []string
+	for name := range config.Get().Commands {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		s.StatusMessage = "No commands, add them to the configuration"
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+	sort.Strings(names)
+
+	// Keep the last used command if it still exists
+	current := ""
+	if s.CommandIndex < len(s.Commands) {
+		current = s.Commands[s.CommandIndex]
+	}
+	s.CommandMode = true
+	s.Commands = names
+	s.CommandIndex = 0
+	for i, name := range names {
+		if name == current {
+			s.CommandIndex = i
+		}
+	}
+}
+
+// Handle a key in the command menu
+func handleCommandKey(s *Selector, key string) {
+	switch key {
+	case "esc":
+		s.CommandMode = false
+	case "tab", "down", "j":
+		s.CommandIndex = (s.CommandIndex + 1) % len(s.Commands)
+	case "shift+tab", "up", "k":
+		s.CommandIndex = (s.CommandIndex + len(s.Commands) - 1) % len(s.Commands)
+	case "enter":
+		s.CommandMode = false
+		prepareCommand(s)
+	}
+}
+
+// Export the selection and prepare the chosen command, which is run in the
+// background by the application
+func prepareCommand(s *Selector) {
+	name := s.Commands[s.CommandIndex]
+	line, ok := config.Get().Commands[name]
+	if !ok {
+		s.StatusMessage = "Command not found: " + name
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+
+	result := export.GenerateTextFile(
+		getSelectedPaths(s.Selection),
+		[]string{}, // Empty excluded paths
+		s.IncludeMode,
+		GetRootDirectory(),
+		exportBaseDir(s),
+		exportOptions(s),
+	)
+	if exportFailed(s, result) {
+		return
+	}
+	if len(result.Parts) == 0 {
+		s.StatusMessage = "Nothing to send"
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+
+	// The parts of a split bundle are sent one after the other
+	var input bytes.Buffer
+	for _, part := range result.Parts {
+		data, err := os.ReadFile(part)
+		os.Remove(part)
+		if err != nil {
+			s.StatusMessage = "Error reading " + part
+			s.StatusTime = time.Now().Unix()
+			return
+		}
+		input.Write(data)
+	}
+
+	s.PendingCommand = &PendingCommand{Name: name, Line: line, Dir: GetRootDirectory(), Input: input.Bytes()}
+	s.StatusMessage = fmt.Sprintf("Running %s with %d files%s...", name, result.Files, exportWarnings(result))
+	s.StatusTime = time.Now().Unix()
+}
+
+// RunCommand runs a command with the bundle on its stdin and captures its
+// standard output and error
+func RunCommand(p PendingCommand) CommandOutput {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", p.Line)
+	} else {
+		cmd = exec.Command("sh", "-c", p.Line)
+	}
+	cmd.Dir = p.Dir
+	cmd.Stdin = bytes.NewReader(p.Input)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	return CommandOutput{Name: p.Name, Output: output.String(), Err: err, Duration: time.Since(start)}
+}
+
+// ShowCommandOutput opens the result pane with the output of a command
+func ShowCommandOutput(s *Selector, out CommandOutput) {
+	text := ansiSequence.ReplaceAllString(out.Output, "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	text = strings.TrimRight(text, "\n")
+
+	// A carriage return rewrites the line (e.g. progress bars), the other
+	// control characters would move the cursor of the interface
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if cr := strings.LastIndex(line, "\r"); cr >= 0 {
+			line = line[cr+1:]
+		}
+		lines[i] = SanitizeLine(line)
+	}
+
+	s.OutputMode = true
+	s.OutputScroll = 0
+	s.OutputLines = lines
+	s.OutputTitle = fmt.Sprintf("%s (%.1fs)", out.Name, out.Duration.Seconds())
+	if out.Err != nil {
+		s.OutputTitle += " - " + out.Err.Error()
+	}
+}
+
+// Handle a key in the result pane
+func handleOutputKey(s *Selector, key string) {
+	_, height := getTerminalSize()
+	page := max(1, height-4)
+	last := max(0, len(s.OutputLines)-page)
+
+	switch key {
+	case "esc", "q":
+		s.OutputMode = false
+		s.OutputLines = nil
+	case "down", "j":
+		s.OutputScroll++
+	case "up", "k":
+		s.OutputScroll--
+	case "pgdown", "ctrl+d", " ":
+		s.OutputScroll += page
+	case "pgup", "ctrl+u":
+		s.OutputScroll -= page
+	case "home", "g":
+		s.OutputScroll = 0
+	case "end", "G":
+		s.OutputScroll = last
+	}
+	s.OutputScroll = max(0, min(s.OutputScroll, last))
+}
@@ -1,14 +1,18 @@
 package core
 
 import (
+	"catselector/config"
+	"catselector/content"
+	"catselector/export"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"golang.org/x/term"
 )
 
@@ -56,6 +60,14 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 			subdirText = White.Render("Subdirectories: ") + Magenta.Render("Not included")
 		}
 
+		// Determine the state of the hidden files
+		var hiddenText string
+		if selector.ShowHidden {
+			hiddenText = White.Render("Hidden: ") + Magenta.Render("Shown")
+		} else {
+			hiddenText = White.Render("Hidden: ") + White.Render("Not shown")
+		}
+
 		// Count selected files and directories
 		selectedFiles, selectedDirs := countSelected(selector)
 		selectedText = White.Render("Selected: ") +
@@ -66,7 +78,8 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 			White.Render(" Directories")
 
 		// Full text with Selected after Included/Not included
-		infoText := subdirText + "   " + selectedText
+		sortText := White.Render("Sort: ") + Magenta.Render(sortDescription())
+		infoText := subdirText + "   " + hiddenText + "   " + sortText + "   " + selectedText
 		header += "\n" + infoText
 	} else {
 		// Split the directory into parts
@@ -98,6 +111,14 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 			subdirText = White.Render("Subdirectories: ") + White.Render("Not included")
 		}
 
+		// Determine the state of the hidden files
+		var hiddenText string
+		if selector.ShowHidden {
+			hiddenText = White.Render("Hidden: ") + Magenta.Render("Shown")
+		} else {
+			hiddenText = White.Render("Hidden: ") + White.Render("Not shown")
+		}
+
 		// Count selected files and directories
 		selectedFiles, selectedDirs := countSelected(selector)
 		selectedText = White.Render("Selected: ") +
@@ -108,7 +129,8 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 			White.Render(" Directories")
 
 		// Full text with Selected after Included/Not included
-		infoText := subdirText + "   " + selectedText
+		sortText := White.Render("Sort: ") + Magenta.Render(sortDescription())
+		infoText := subdirText + "   " + hiddenText + "   " + sortText + "   " + selectedText
 		header += "\n" + infoText
 	}
 
@@ -150,10 +172,11 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 	}
 
 	// Count elements in the determined directory
-	totalItems, err = countItems(countDir)
+	showHidden := GetCurrentSelector().ShowHidden
+	totalItems, err = countItems(countDir, showHidden)
 	if err == nil {
-		totalFiles, _ = countFiles(countDir)
-		totalSubdirs, _ = countSubdirs(countDir)
+		totalFiles, _ = countFiles(countDir, showHidden)
+		totalSubdirs, _ = countSubdirs(countDir, showHidden)
 	}
 
 	// Add counters to the headers
@@ -272,6 +295,24 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 				len(selector.Files))
 			statusBar = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(searchText)
 		}
+	} else if selector != nil && selector.PromptMode {
+		promptText := fmt.Sprintf("Template %s (%d/%d) > %s_  [Tab: next template, Enter: copy, Esc: cancel]",
+			selector.PromptTemplates[selector.PromptTemplate],
+			selector.PromptTemplate+1,
+			len(selector.PromptTemplates),
+			selector.PromptText)
+		statusBar = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(promptText)
+	} else if selector != nil && selector.CommandMode {
+		commandText := fmt.Sprintf("Send to %s (%d/%d): %s  [Tab: next command, Enter: run, Esc: cancel]",
+			selector.Commands[selector.CommandIndex],
+			selector.CommandIndex+1,
+			len(selector.Commands),
+			config.Get().Commands[selector.Commands[selector.CommandIndex]])
+		statusBar = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(commandText)
+	} else if selector != nil && selector.VisualMode {
+		start, end := visualRange(selector, selector.Position)
+		visualText := fmt.Sprintf("-- VISUAL -- %d items [s: toggle range, Esc: cancel]", end-start+1)
+		statusBar = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(visualText)
 	} else if selector != nil && selector.StatusMessage != "" && time.Now().Unix()-selector.StatusTime < 3 {
 		statusBar = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render(selector.StatusMessage)
 	} else {
@@ -285,15 +326,17 @@ func DrawLayout(position int, items []string, currentDir string, files []string,
 		{"k/j", "Up or Down"},
 		{"Enter/l", "Enter"},
 		{"Esc/h", "Back"},
-		{"o/c", "Open or Copy"},
+		{"o/c/z", "Open, Copy or Archive"},
 		{"s/a", "Select or All"},
 		{"i", "Include"},
+		{"v/I", "Visual or Invert"},
 		{"/", "Search"},
+		{"t/p", "Template or Pipe"},
 		{"Tab/q", "Change Panel or Quit"},
 	}
 
 	// Calculate the available width and the number of shortcuts per line
-	numPerLine := 4 // 4 elements per row
+	numPerLine := (len(keyBindings) + 1) / 2 // Split the elements in two rows
 	width, _ = getTerminalSize()
 
 	var line1, line2 string
@@ -397,6 +440,8 @@ func renderFilePanel(files []string, position, panelWidth, height, panelHeight i
 		style := White
 		if activePanel == 2 && i == filePosition {
 			style = Focus
+		} else if InVisualRange(selector, 2, i) {
+			style = Visual
 		} else if isSelected {
 			style = Yellow
 		}
@@ -458,11 +503,11 @@ func renderPreviewPanel(dir string, width, height int, files []string, filePosit
 		// Filter only directories and sort them
 		var subdirs []string
 		for _, entry := range entries {
-			if entry.IsDir() {
+			if entry.IsDir() && !isHiddenEntry(entry.Name(), selector.ShowHidden) {
 				subdirs = append(subdirs, entry.Name())
 			}
 		}
-		sort.Strings(subdirs)
+		export.SortNames(selectedDir, subdirs, config.Get().Sort)
 
 		// Show the subdirectories
 		for i := 0; i < height && i < len(subdirs); i++ {
@@ -532,11 +577,11 @@ func renderPreviewPanel(dir string, width, height int, files []string, filePosit
 				return b.String()
 			}
 
-			// Read the content of the file
-			content, err := os.ReadFile(filePath)
+			// Read the content of the file, converted to UTF-8 if necessary
+			text, _, err := content.ReadFile(filePath)
 			if err == nil {
-				// Convert the content to string and limit to the first lines that fit
-				lines := strings.Split(string(content), "\n")
+				// Limit to the first lines that fit
+				lines := strings.Split(text, "\n")
 
 				// Limit the number of lines to avoid overflows
 				maxLines := height
@@ -592,8 +637,42 @@ func renderPreviewPanel(dir string, width, height int, files []string, filePosit
 }
 
 // Get the terminal size
+// Terminal the interface is drawn on
+var terminal = os.Stdout
+
+// SetTerminal draws the interface on another terminal than stdout, e.g.
+// /dev/tty when stdout is captured by the shell
+func SetTerminal(tty *os.File) {
+	terminal = tty
+	lipgloss.DefaultRenderer().SetOutput(termenv.NewOutput(tty))
+}
+
+// ProgramOutput is the output given to the program. Its writes are
+// serialized, so that a sequence written between two frames (OSC 52)
+// never lands in the middle of one.
+type ProgramOutput struct {
+	*os.File
+	mu sync.Mutex
+}
+
+func (o *ProgramOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.File.Write(p)
+}
+
+// Output of the running program, nil outside of the interface
+var programOutput *ProgramOutput
+
+// NewProgramOutput returns the output of the program, on the terminal the
+// interface is drawn on
+func NewProgramOutput() *ProgramOutput {
+	programOutput = &ProgramOutput{File: terminal}
+	return programOutput
+}
+
 func getTerminalSize() (int, int) {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	width, height, err := term.GetSize(int(terminal.Fd()))
 	if err != nil {
 		width, height = 80, 24 // Default terminal size if error occurs
 	}
@@ -649,6 +728,8 @@ func renderLeftPanel(items []string, selected map[string]bool, directory string,
 		style := Green
 		if hasFocus {
 			style = Focus
+		} else if InVisualRange(selector, 1, i) {
+			style = Visual
 		} else if isSelected {
 			style = Yellow
 		}
@@ -677,30 +758,37 @@ func SetCurrentSelector(s *Selector) {
 func GetCurrentSelector() *Selector {
 	if currentSelector == nil {
 		currentSelector = &Selector{
-			Selection: make(map[string]bool),
+			Selection:  make(map[string]bool),
+			SelectedAt: make(map[string]int64),
 		}
 	}
 	return currentSelector
 }
 
 // countItems counts the total number of elements (files + subdirectories) in a directory
-func countItems(dir string) (int, error) {
+func countItems(dir string, showHidden bool) (int, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return 0, err
 	}
-	return len(entries), nil
+	count := 0
+	for _, entry := range entries {
+		if !isHiddenEntry(entry.Name(), showHidden) {
+			count++
+		}
+	}
+	return count, nil
 }
 
 // countFiles counts the number of files in a directory
-func countFiles(dir string) (int, error) {
+func countFiles(dir string, showHidden bool) (int, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return 0, err
 	}
 	count := 0
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() && !isHiddenEntry(entry.Name(), showHidden) {
 			count++
 		}
 	}
@@ -708,14 +796,14 @@ func countFiles(dir string) (int, error) {
 }
 
 // countSubdirs counts the number of subdirectories in a directory
-func countSubdirs(dir string) (int, error) {
+func countSubdirs(dir string, showHidden bool) (int, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return 0, err
 	}
 	count := 0
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && !isHiddenEntry(entry.Name(), showHidden) {
 			count++
 		}
 	}
@@ -748,26 +836,7 @@ func countSelected(selector *Selector) (int, int) {
 			processedDirs[key] = true
 
 			// Count files in the directory and its subdirectories
-			if selector.IncludeMode {
-				// Count recursively all files in subdirectories
-				filepath.Walk(key, func(p string, info os.FileInfo, err error) error {
-					if err == nil && !info.IsDir() {
-						selectedFiles++
-					}
-					return nil
-				})
-			} else {
-				// Count only files in the top level of the directory
-				files, err := os.ReadDir(key)
-				if err == nil {
-					for _, file := range files {
-						fileInfo, err := file.Info()
-						if err == nil && !fileInfo.IsDir() {
-							selectedFiles++
-						}
-					}
-				}
-			}
+			selectedFiles += countVisibleFiles(key, selector.IncludeMode, selector.ShowHidden)
 		} else {
 			selectedFiles++
 		}
@@ -776,6 +845,34 @@ func countSelected(selector *Selector) (int, int) {
 	return selectedFiles, selectedDirs
 }
 
+// DrawOutputPane renders the output of a command over the whole screen
+func DrawOutputPane(s *Selector) string {
+	width, height := getTerminalSize()
+	page := max(1, height-4)
+
+	var result strings.Builder
+	title := " Output: " + s.OutputTitle + " "
+	result.WriteString(ActiveHeader.Render(lipgloss.PlaceHorizontal(width, lipgloss.Left, title)) + "\n")
+
+	for i := 0; i < page; i++ {
+		index := s.OutputScroll + i
+		if index < len(s.OutputLines) {
+			line := []rune(s.OutputLines[index])
+			if len(line) > width {
+				line = line[:width]
+			}
+			result.WriteString(string(line))
+		}
+		result.WriteString("\n")
+	}
+
+	end := min(len(s.OutputLines), s.OutputScroll+page)
+	footer := fmt.Sprintf("Lines %d-%d of %d [j/k: scroll, Space/PgUp: page, g/G: top or bottom, Esc/q: close]",
+		min(s.OutputScroll+1, end), end, len(s.OutputLines))
+	result.WriteString(Yellow.Render(footer))
+	return result.String()
+}
+
 // Helper function to get the minimum of two numbers
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"catselector/config"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// EditorCommand builds the command opening a file in $VISUAL or $EDITOR,
+// which may include arguments and quotes (e.g. "code -w"). The shell
+// parses it, and the file is passed as a separate argument.
+func EditorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if strings.TrimSpace(editor) == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	if runtime.GOOS == "windows" {
+		fields := strings.Fields(editor)
+		return exec.Command(fields[0], append(fields[1:], path)...)
+	}
+	return exec.Command("sh", "-c", editor+` "$1"`, "catsel", path)
+}
+
+// Check if the bundles must be opened in the terminal editor instead of
+// the application of the system
+func useEditor() bool {
+	switch config.Get().OpenWith {
+	case config.OpenEditor:
+		return true
+	case config.OpenSystem:
+		return false
+	}
+
+	// Without a graphical session there is nothing to open the file with
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return false
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return true
+	}
+	for _, opener := range []string{"xdg-open", "gnome-open", "kde-open"} {
+		if _, err := exec.LookPath(opener); err == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"catselector/config"
 	"catselector/export"
 	"fmt"
 	"os"
@@ -27,7 +28,7 @@ type SearchResults struct {
 }
 
 // Function to search recursively and separate results
-func searchRecursively(rootDir string, query string) SearchResults {
+func searchRecursively(rootDir string, query string, showHidden bool) SearchResults {
 	var results SearchResults
 	query = strings.ToLower(query)
 
@@ -42,6 +43,14 @@ func searchRecursively(rootDir string, query string) SearchResults {
 			return nil
 		}
 
+		// Skip hidden entries and the content of hidden directories
+		if path != rootDir && isHiddenEntry(info.Name(), showHidden) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// If the name contains the query, add it to the corresponding results
 		if strings.Contains(strings.ToLower(relPath), query) {
 			if info.IsDir() {
@@ -66,6 +75,7 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			s.SearchMode = false
 			s.SearchQuery = ""
 			s.Filtered = s.OriginalItems
+			s.IsSearching = false
 			s.Files = []string{} // Clear the files
 			return position
 		case "enter":
@@ -89,6 +99,7 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			}
 			// If there are no results, return to normal view
 			s.Filtered = s.OriginalItems
+			s.IsSearching = false
 			s.Files = []string{}
 			return position
 
@@ -98,10 +109,12 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 				s.SearchQuery = s.SearchQuery[:len(s.SearchQuery)-1]
 				if s.SearchQuery == "" {
 					s.Filtered = s.OriginalItems
+					s.IsSearching = false
 					s.Files = []string{}
 				} else {
-					results := searchRecursively(GetRootDirectory(), s.SearchQuery)
+					results := searchRecursively(GetRootDirectory(), s.SearchQuery, s.ShowHidden)
 					s.Filtered = results.Directories
+					s.IsSearching = true
 					s.Files = results.Files
 				}
 			}
@@ -110,16 +123,109 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			// Add a character to the search
 			if len(key) == 1 {
 				s.SearchQuery += key
-				results := searchRecursively(GetRootDirectory(), s.SearchQuery)
+				results := searchRecursively(GetRootDirectory(), s.SearchQuery, s.ShowHidden)
 				s.Filtered = results.Directories
+				s.IsSearching = true
 				s.Files = results.Files
 			}
 			return position
 		}
 	}
 
+	// The dialogs and the result pane take all the keys while they are open
+	if s.PromptMode {
+		handlePromptKey(s, key)
+		return position
+	}
+	if s.CommandMode {
+		handleCommandKey(s, key)
+		return position
+	}
+	if s.OutputMode {
+		handleOutputKey(s, key)
+		return position
+	}
+
+	// In visual mode, leave the mode before actions that change the listing
+	if s.VisualMode {
+		switch key {
+		case "esc", "v", "V":
+			s.VisualMode = false
+			return position
+		case "s":
+			// Toggle the whole range and leave visual mode
+			toggleVisualRange(s, position, items)
+			s.VisualMode = false
+			return position
+		case "tab", "f", "d", "enter", "l", "h", "/", "a", "I":
+			s.VisualMode = false
+		}
+	}
+
 	// Normal key handling
 	switch key {
+	case "v", "V":
+		// Enter visual mode in the active panel
+		StartVisualMode(s, position)
+	case "I":
+		// Invert the selection of the current directory
+		invertSelection(s, items)
+	case ".":
+		// Toggle hidden files and keep the cursor on the same directory
+		s.ShowHidden = !s.ShowHidden
+		current := ""
+		if position >= 0 && position < len(items) {
+			current = items[position]
+		}
+		s.Filtered = PrepareDirItems(s.Directory, s.ShowHidden)
+		s.IsSearching = false
+		items = s.Filtered
+		position = 0
+		for i, item := range items {
+			if item == current {
+				position = i
+				break
+			}
+		}
+		keepDirCursorVisible(s, position)
+		s.FilePosition = 0
+		s.FileScroll = 0
+		if s.ShowHidden {
+			s.StatusMessage = "Showing hidden files"
+		} else {
+			s.StatusMessage = "Hiding hidden files"
+		}
+		s.StatusTime = time.Now().Unix()
+	case "S", "R":
+		// Cycle the sort mode or reverse the order, and persist it
+		cfg := config.Get()
+		if key == "S" {
+			cfg.Sort.Mode = export.NextSortMode(cfg.Sort.Mode)
+		} else {
+			cfg.Sort.Descending = !cfg.Sort.Descending
+		}
+		current := ""
+		if position >= 0 && position < len(items) {
+			current = items[position]
+		}
+		s.Filtered = PrepareDirItems(s.Directory, s.ShowHidden)
+		s.IsSearching = false
+		items = s.Filtered
+		for i, item := range items {
+			if item == current {
+				position = i
+				break
+			}
+		}
+		keepDirCursorVisible(s, position)
+		s.FilePosition = 0
+		s.FileScroll = 0
+		if err := config.SaveSort(); err != nil {
+			s.StatusMessage = "Sort: " + sortDescription() + " (not saved: " + err.Error() + ")"
+		} else {
+			s.StatusMessage = "Sort: " + sortDescription()
+		}
+		s.StatusTime = time.Now().Unix()
 	case "/":
 		// Enter search mode
 		s.SearchMode = true
@@ -128,9 +234,10 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 		return position
 	case "esc", "h":
 		// Si estamos en una búsqueda, volver a la vista normal
-		if s.SearchMode || (len(s.Filtered) != len(s.OriginalItems) && len(s.OriginalItems) > 0) {
+		if s.SearchMode || s.IsSearching {
 			s.SearchMode = false
 			s.Filtered = s.OriginalItems
+			s.IsSearching = false
 			s.Files = []string{}
 			s.DirScroll = 0
 			s.FileScroll = 0
@@ -141,7 +248,7 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 		rootDir := GetRootDirectory()
 
 		// Si no estamos en el directorio raíz, ir al directorio padre
-		if s.Directory != rootDir {
+		if canGoUp(s.Directory) {
 			// Guardar el estado actual en el historial antes de cambiar
 			if len(s.History) == 0 || s.History[len(s.History)-1].Directory != s.Directory {
 				s.History = append(s.History, NavigationHistory{
@@ -156,7 +263,8 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			// Verificar que el directorio padre existe y es accesible
 			if info, err := os.Stat(parentDir); err == nil && info.IsDir() {
 				s.Directory = parentDir
-				s.Filtered = PrepareDirItems(parentDir)
+				s.Filtered = PrepareDirItems(parentDir, s.ShowHidden)
+				s.IsSearching = false
 
 				// Buscar la posición del directorio actual en la nueva lista
 				// currentDirName := filepath.Base(s.Directory)
@@ -256,59 +364,128 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 	case "o":
 		// Export and open in external application
 		selectedPaths := getSelectedPaths(s.Selection)
-		outputFile := export.GenerateTextFile(
+		result := export.GenerateTextFile(
 			selectedPaths,
 			[]string{}, // Empty excluded paths
 			s.IncludeMode,
 			GetRootDirectory(),
-			s.Directory,
+			exportBaseDir(s),
+			exportOptions(s),
 		)
-		if outputFile != "" {
-			// Open the file without exiting the alternative mode
-			err := OpenTextFile(outputFile)
+		if exportFailed(s, result) {
+			break
+		}
+		if len(result.Parts) > 0 && useEditor() {
+			// Open the file (the first part of a split bundle) in the terminal editor
+			s.PendingEdit = result.Parts[0]
+		} else if len(result.Parts) > 0 {
+			// Open the file (the first part of a split bundle) without exiting the alternative mode
+			err := OpenTextFile(result.Parts[0])
 
 			// Show success or error message
-			if err == nil {
-				s.StatusMessage = "Opened file: " + filepath.Base(outputFile)
+			if err != nil {
+				s.StatusMessage = "Error opening file"
+			} else if len(result.Parts) > 1 {
+				s.StatusMessage = fmt.Sprintf("Opened part 1/%d: %s (all parts in %s)%s",
+					len(result.Parts), filepath.Base(result.Parts[0]), filepath.Dir(result.Parts[0]),
+					exportWarnings(result))
 			} else {
+				s.StatusMessage = "Opened file: " + filepath.Base(result.Parts[0]) + exportWarnings(result)
+			}
+			s.StatusTime = time.Now().Unix()
+		}
+	case "O":
+		// Export an HTML report and open it in the browser
+		selectedPaths := getSelectedPaths(s.Selection)
+		result := export.GenerateHTMLFile(
+			selectedPaths,
+			[]string{}, // Empty excluded paths
+			s.IncludeMode,
+			GetRootDirectory(),
+			exportBaseDir(s),
+			exportOptions(s),
+		)
+		if exportFailed(s, result) {
+			break
+		}
+		if len(result.Parts) > 0 {
+			if err := OpenTextFile(result.Parts[0]); err != nil {
 				s.StatusMessage = "Error opening file"
+			} else {
+				s.StatusMessage = "Opened report: " + filepath.Base(result.Parts[0]) + exportWarnings(result)
 			}
 			s.StatusTime = time.Now().Unix()
 		}
 	case "c":
 		// Export and copy to clipboard and delete file
+		discardClipboardParts(s)
 		selectedPaths := getSelectedPaths(s.Selection)
-		outputFile := export.GenerateTextFile(
+		result := export.GenerateTextFile(
 			selectedPaths,
 			[]string{}, // Empty excluded paths
 			s.IncludeMode,
 			GetRootDirectory(),
-			s.Directory,
+			exportBaseDir(s),
+			exportOptions(s),
 		)
+		if exportFailed(s, result) {
+			break
+		}
 
-		if outputFile != "" {
-			// Read the content of the file
-			content, err := os.ReadFile(outputFile)
-			if err == nil {
-				// Copy to clipboard according to the operating system
-				success := CopyToClipboard(string(content))
-
-				// Delete the temporary file
-				os.Remove(outputFile)
-
-				// Count selected files for the status message
-				selectedFiles := countSelectedFiles(s)
-
-				// Prepare message and save status
-				msg := ""
-				if success {
-					msg = fmt.Sprintf("%d files copied to clipboard", selectedFiles)
-				} else {
-					msg = "Error copying to clipboard"
-				}
-				s.StatusMessage = msg
-				s.StatusTime = time.Now().Unix()
-			}
+		if len(result.Parts) > 0 {
+			// Copy the first part, the next ones are copied with "n"
+			s.ClipboardParts = result.Parts
+			s.ClipboardPart = 0
+			s.ClipboardFiles = result.Files
+			s.ClipboardWarnings = exportWarnings(result)
+			copyNextPart(s)
+		}
+	case "z":
+		// Export the selected files themselves as an archive
+		format := config.Get().ArchiveFormat
+		if format != export.FormatZip {
+			format = export.FormatTarGz
+		}
+		selectedPaths := getSelectedPaths(s.Selection)
+		result := export.GenerateArchive(
+			selectedPaths,
+			[]string{}, // Empty excluded paths
+			s.IncludeMode,
+			GetRootDirectory(),
+			exportBaseDir(s),
+			format,
+			exportOptions(s),
+		)
+		if exportFailed(s, result) {
+			break
+		}
+		if len(result.Parts) > 0 {
+			s.StatusMessage = fmt.Sprintf("%d files archived in %s%s", result.Files, result.Parts[0], exportWarnings(result))
+		} else {
+			s.StatusMessage = "Error creating archive"
+		}
+		s.StatusTime = time.Now().Unix()
+	case "t":
+		// Choose a prompt template, enter the prompt and copy the result
+		StartPromptMode(s)
+	case "e":
+		// Edit the file under the cursor in the terminal editor
+		if s.ActivePanel == 2 && s.FilePosition >= 0 && s.FilePosition < len(s.Files) {
+			s.PendingEdit = s.GetFileSelectionKey(s.Files[s.FilePosition])
+		} else {
+			s.StatusMessage = "Move to the files panel to edit a file"
+			s.StatusTime = time.Now().Unix()
+		}
+	case "w":
+		// Start or stop regenerating the bundle when the selected files change
+		ToggleWatch(s)
+	case "p":
+		// Choose a command and send the bundle to its stdin
+		StartCommandMode(s)
+	case "n":
+		// Copy the next part of a split bundle
+		if s.ClipboardPart < len(s.ClipboardParts) {
+			copyNextPart(s)
 		}
 	case "tab":
 		// Save the previous panel
@@ -338,14 +515,8 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 				// Check if the directory exists and is accessible
 				if info, err := os.Stat(selectedDir); err == nil && info.IsDir() {
 					// Update the file list for the selected directory
-					files, err := os.ReadDir(selectedDir)
+					fileList, err := ListFiles(selectedDir, s.ShowHidden)
 					if err == nil {
-						var fileList []string
-						for _, file := range files {
-							if !file.IsDir() { // Solo archivos
-								fileList = append(fileList, file.Name())
-							}
-						}
 						s.Files = fileList // Update the files
 						s.FilePosition = 0 // Reset the position in the file panel
 						s.FileScroll = 0   // Reset the scroll position
@@ -390,7 +561,8 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 				})
 
 				s.Directory = newDir
-				s.Filtered = PrepareDirItems(newDir)
+				s.Filtered = PrepareDirItems(newDir, s.ShowHidden)
+				s.IsSearching = false
 
 				// Search for the position of "." in the new list
 				for i, item := range s.Filtered {
@@ -438,7 +610,7 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			selectedFile := s.Files[s.FilePosition]
 			// Change the selection state
 			fileKey := s.GetFileSelectionKey(selectedFile)
-			s.Selection[fileKey] = !s.Selection[fileKey]
+			s.SetSelected(fileKey, !s.Selection[fileKey])
 		}
 	case "a":
 		if s.ActivePanel == 1 {
@@ -475,7 +647,7 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 			// If not all are selected, select all
 			for _, file := range s.Files {
 				fileKey := s.GetFileSelectionKey(file)
-				s.Selection[fileKey] = !allSelected
+				s.SetSelected(fileKey, !allSelected)
 			}
 		}
 	}
@@ -489,17 +661,22 @@ func HandleKeyPress(key string, position, itemCount int, selected map[string]boo
 	return position
 }
 
+// Adjust the scroll of the directory panel so the cursor stays visible
+func keepDirCursorVisible(s *Selector, position int) {
+	_, height := getTerminalSize()
+	visibleLines := height - 9 // 9 lines for headers and other elements
+	if position < s.DirScroll {
+		s.DirScroll = position
+	} else if position >= s.DirScroll+visibleLines {
+		s.DirScroll = max(0, position-visibleLines+1)
+	}
+}
+
 // UpdateFileList updates the file list for a directory
 func UpdateFileList(selector *Selector, currentDir string, item string) {
 	dirPath := filepath.Join(currentDir, item)
-	files, err := os.ReadDir(dirPath)
+	fileList, err := ListFiles(dirPath, selector.ShowHidden)
 	if err == nil {
-		var fileList []string
-		for _, file := range files {
-			if !file.IsDir() { // Solo archivos
-				fileList = append(fileList, file.Name())
-			}
-		}
 		selector.Files = fileList
 	}
 }
@@ -515,51 +692,60 @@ func getSelectedPaths(selection map[string]bool) []string {
 	return paths
 }
 
-// Count selected files
-func countSelectedFiles(s *Selector) int {
-	count := 0
-	processedDirs := make(map[string]bool)
-
-	for path, selected := range s.Selection {
-		if !selected {
-			continue
-		}
+// Delete the parts of the last export that were not copied, before
+// a new export possibly reuses their names
+func discardClipboardParts(s *Selector) {
+	for _, part := range s.ClipboardParts[s.ClipboardPart:] {
+		os.Remove(part)
+	}
+	s.ClipboardParts = nil
+	s.ClipboardPart = 0
+}
 
-		info, err := os.Stat(path)
-		if err != nil {
-			continue
-		}
+// Copy the next pending part of the last export to the clipboard
+// and delete its file
+func copyNextPart(s *Selector) {
+	outputFile := s.ClipboardParts[s.ClipboardPart]
+	total := len(s.ClipboardParts)
+	s.ClipboardPart++
+
+	// Read the content of the file
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		s.StatusMessage = "Error reading " + filepath.Base(outputFile)
+		s.StatusTime = time.Now().Unix()
+		return
+	}
 
-		if !info.IsDir() {
-			count++
-		} else if s.IncludeMode {
-			// If the directory has already been processed, skip it
-			if processedDirs[path] {
-				continue
-			}
-			processedDirs[path] = true
+	// Copy to clipboard according to the operating system
+	clipboardNote, err := CopyToClipboard(string(content))
+
+	// Delete the temporary file
+	os.Remove(outputFile)
+
+	// Prepare message and save status
+	msg := ""
+	if err != nil {
+		msg = "Error copying to clipboard (run catsel doctor): " + err.Error()
+	} else if total == 1 {
+		msg = fmt.Sprintf("%d files copied to clipboard%s", s.ClipboardFiles, s.ClipboardWarnings)
+	} else if s.ClipboardPart < total {
+		msg = fmt.Sprintf("Part %d/%d copied to clipboard (%d files%s), press n to copy the next part",
+			s.ClipboardPart, total, s.ClipboardFiles, s.ClipboardWarnings)
+	} else {
+		msg = fmt.Sprintf("Part %d/%d copied to clipboard, all parts copied", s.ClipboardPart, total)
+	}
+	if err == nil {
+		msg += clipboardNote
+	}
+	s.StatusMessage = msg
+	s.StatusTime = time.Now().Unix()
 
-			// Count files in the directory and subdirectories
-			filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
-				if err == nil && !info.IsDir() {
-					count++
-				}
-				return nil
-			})
-		} else {
-			// Count only files in the top level of the directory
-			files, err := os.ReadDir(path)
-			if err == nil {
-				for _, file := range files {
-					fileInfo, err := file.Info()
-					if err == nil && !fileInfo.IsDir() {
-						count++
-					}
-				}
-			}
-		}
+	// Forget the parts once all of them are copied
+	if s.ClipboardPart >= total {
+		s.ClipboardParts = nil
+		s.ClipboardPart = 0
 	}
-	return count
 }
 
 func filterItems(items []string, query string) []string {
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"catselector/export"
+	"path/filepath"
+)
+
+// PickedPaths returns the selected files in the export order, with the
+// selected directories expanded. Without a selection the file under the
+// cursor in the files panel is picked. The paths are relative to the
+// current directory unless absolute is set.
+func PickedPaths(s *Selector, absolute bool) []string {
+	files := export.SelectedFiles(getSelectedPaths(s.Selection), []string{}, s.IncludeMode, exportBaseDir(s), exportOptions(s))
+	if len(files) == 0 && s.ActivePanel == 2 && s.FilePosition < len(s.Files) {
+		files = []string{s.GetFileSelectionKey(s.Files[s.FilePosition])}
+	}
+	if absolute {
+		return files
+	}
+
+	baseDir := GetCurrentDirectory()
+	for i, path := range files {
+		if rel, err := filepath.Rel(baseDir, path); err == nil {
+			files[i] = rel
+		}
+	}
+	return files
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReadPathList reads a list of paths separated by newlines, or by NUL
+// characters if there are any (find -print0, git diff -z)
+func ReadPathList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sep := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = "\x00"
+	}
+	var paths []string
+	for _, line := range strings.Split(string(data), sep) {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// Preselect selects the given paths, relative to the current directory,
+// keeping their order as the selection order. Paths that do not exist or
+// are outside of the root are ignored; the status bar reports the counts.
+func Preselect(s *Selector, paths []string) {
+	baseDir := GetCurrentDirectory()
+	rootDir := GetRootDirectory()
+	now := time.Now().UnixNano()
+	selected, missing, outside := 0, 0, 0
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		path = filepath.Clean(path)
+		if _, err := os.Stat(path); err != nil {
+			missing++
+			continue
+		}
+		if !aboveRoot && !isInside(rootDir, path) {
+			outside++
+			continue
+		}
+		if s.Selection[path] {
+			continue
+		}
+		s.Selection[path] = true
+		s.SelectedAt[path] = now + int64(selected)
+		selected++
+	}
+
+	s.StatusMessage = fmt.Sprintf("%d paths preselected", selected)
+	if missing > 0 {
+		s.StatusMessage += fmt.Sprintf(", %d not found", missing)
+	}
+	if outside > 0 {
+		s.StatusMessage += fmt.Sprintf(", %d outside of the root", outside)
+	}
+	s.StatusTime = time.Now().Unix()
+}
@@ -10,7 +10,7 @@ import (
 func processDirectoryRecursive(selector *Selector, dirPath string, item string, selectState bool) {
 	// Update the selection state of the current directory
 	selectionKey := selector.GetSelectionKey(item)
-	selector.Selection[selectionKey] = selectState
+	selector.SetSelected(selectionKey, selectState)
 
 	// If we are deselecting, clear all files and subdirectories
 	// of the current directory from the selection map
@@ -19,7 +19,7 @@ func processDirectoryRecursive(selector *Selector, dirPath string, item string,
 		prefix := dirPath + string(os.PathSeparator)
 		for path := range selector.Selection {
 			if strings.HasPrefix(path, prefix) {
-				selector.Selection[path] = false
+				selector.SetSelected(path, false)
 			}
 		}
 		return
@@ -32,7 +32,7 @@ func processDirectoryRecursive(selector *Selector, dirPath string, item string,
 		entries, err := os.ReadDir(dirPath)
 		if err == nil {
 			for _, entry := range entries {
-				if entry.IsDir() {
+				if entry.IsDir() && !isHiddenEntry(entry.Name(), selector.ShowHidden) {
 					// Process recursively the subdirectory
 					subDirPath := filepath.Join(dirPath, entry.Name())
 					subItem := filepath.Join(item, entry.Name())
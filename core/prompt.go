@@ -0,0 +1,95 @@
+package core
+
+import (
+	"catselector/config"
+	"catselector/export"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Open the prompt dialog to choose a template and enter the prompt
+func StartPromptMode(s *Selector) {
+	names := export.TemplateNames(export.FindTemplates(config.Get().Templates, GetRootDirectory()))
+	if len(names) == 0 {
+		s.StatusMessage = "No prompt templates, add them to the configuration or to .catsel/templates"
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+
+	// Keep the last used template if it still exists
+	current := ""
+	if s.PromptTemplate < len(s.PromptTemplates) {
+		current = s.PromptTemplates[s.PromptTemplate]
+	}
+	s.PromptMode = true
+	s.PromptTemplates = names
+	s.PromptTemplate = 0
+	for i, name := range names {
+		if name == current {
+			s.PromptTemplate = i
+		}
+	}
+	s.PromptText = ""
+}
+
+// Handle a key in the prompt dialog
+func handlePromptKey(s *Selector, key string) {
+	switch key {
+	case "esc":
+		s.PromptMode = false
+	case "tab":
+		s.PromptTemplate = (s.PromptTemplate + 1) % len(s.PromptTemplates)
+	case "shift+tab":
+		s.PromptTemplate = (s.PromptTemplate + len(s.PromptTemplates) - 1) % len(s.PromptTemplates)
+	case "backspace":
+		if s.PromptText != "" {
+			_, size := utf8.DecodeLastRuneInString(s.PromptText)
+			s.PromptText = s.PromptText[:len(s.PromptText)-size]
+		}
+	case "enter":
+		s.PromptMode = false
+		exportWithTemplate(s)
+	default:
+		// Add a character to the prompt
+		if utf8.RuneCountInString(key) == 1 {
+			s.PromptText += key
+		}
+	}
+}
+
+// Export the selection through the chosen template and copy it to the clipboard
+func exportWithTemplate(s *Selector) {
+	name := s.PromptTemplates[s.PromptTemplate]
+	text, ok := export.FindTemplates(config.Get().Templates, GetRootDirectory())[name]
+	if !ok {
+		s.StatusMessage = "Template not found: " + name
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+
+	discardClipboardParts(s)
+	opts := exportOptions(s)
+	opts.Template = text
+	opts.Prompt = s.PromptText
+	result := export.GenerateTextFile(
+		getSelectedPaths(s.Selection),
+		[]string{}, // Empty excluded paths
+		s.IncludeMode,
+		GetRootDirectory(),
+		exportBaseDir(s),
+		opts,
+	)
+	if result.Error != nil {
+		s.StatusMessage = fmt.Sprintf("Template %s: %v", name, result.Error)
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+	if len(result.Parts) > 0 {
+		s.ClipboardParts = result.Parts
+		s.ClipboardPart = 0
+		s.ClipboardFiles = result.Files
+		s.ClipboardWarnings = exportWarnings(result)
+		copyNextPart(s)
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"catselector/watch"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LiveWatch reports the changes of the directories shown in the panels and
+// of the directories holding selected entries
+type LiveWatch struct {
+	Changes chan struct{} // Receives a value after changes, once debounced
+	dirs    string        // Watched directories, to know when to restart
+	stop    chan struct{}
+}
+
+// Done returns a channel closed when the watch is stopped
+func (w *LiveWatch) Done() <-chan struct{} {
+	return w.stop
+}
+
+// CursorDir returns the directory under the cursor of the directory panel,
+// or "" when the cursor is on "." or ".."
+func CursorDir(s *Selector) string {
+	if s.Position >= 0 && s.Position < len(s.Filtered) {
+		if item := s.Filtered[s.Position]; item != "." && item != ".." {
+			return filepath.Join(s.Directory, item)
+		}
+	}
+	return ""
+}
+
+// UpdateLiveWatch watches the current directory, the directory the cursor
+// rests on (LiveCursor) and the parents of the selected entries, restarting
+// the watch when that set changes
+func UpdateLiveWatch(s *Selector) {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	add(s.Directory)
+	add(s.LiveCursor)
+	for key, selected := range s.Selection {
+		if selected && key != "." && key != ".." {
+			add(filepath.Dir(key))
+		}
+	}
+	sort.Strings(dirs)
+	key := strings.Join(dirs, "\x00")
+	if s.Live != nil && s.Live.dirs == key {
+		return
+	}
+
+	if s.Live != nil {
+		close(s.Live.stop)
+	}
+	live := &LiveWatch{Changes: make(chan struct{}, 1), dirs: key, stop: make(chan struct{})}
+	watch.Watch(dirs, watch.Options{Shallow: true, Debounce: 200 * time.Millisecond}, func([]string) {
+		select {
+		case live.Changes <- struct{}{}:
+		default:
+		}
+	}, live.stop)
+	s.Live = live
+}
+
+// Refresh the panels after a change in the file system and remove the
+// selected entries that no longer exist. The refresh waits while a search,
+// a visual range or a dialog is active, since they depend on the listing.
+func RefreshAfterChange(s *Selector) {
+	if s.Capturing() || s.VisualMode || s.IsSearching {
+		s.RefreshPending = true
+		return
+	}
+	s.RefreshPending = false
+	RefreshListing(s)
+
+	if pruned := PruneSelection(s); pruned > 0 {
+		s.StatusMessage = fmt.Sprintf("%d deleted entries removed from the selection", pruned)
+		s.StatusTime = time.Now().Unix()
+	}
+}
+
+// PruneSelection removes the selection keys of deleted files and
+// directories, returning how many selected entries were removed
+func PruneSelection(s *Selector) int {
+	pruned := 0
+	for key, selected := range s.Selection {
+		if key == "." || key == ".." {
+			continue
+		}
+		if _, err := os.Lstat(key); os.IsNotExist(err) {
+			delete(s.Selection, key)
+			delete(s.SelectedAt, key)
+			if selected {
+				pruned++
+			}
+		}
+	}
+	return pruned
+}
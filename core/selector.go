@@ -3,6 +3,7 @@ package core
 import (
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Structure to maintain the navigation history
@@ -18,10 +19,12 @@ type Selector struct {
 	Position     int               // Current position in the directory panel
 	FilePosition int               // Current position in the files panel
 	Selection    map[string]bool   // Selected items (key: relative path to the current directory)
+	SelectedAt   map[string]int64  // Time when each item was selected, to export in selection order
 	Filtered     []string          // Items filtered to display
 	Files        []string          // Files in the current directory
 	History      []NavigationHistory // Navigation history
 	IncludeMode  bool              // Include mode for subdirectories
+	ShowHidden   bool              // Show hidden files and directories
 	StatusMessage string           // Status message to display to the user
 	StatusTime   int64             // Time when the status message was set
 	DirScroll    int               // Scroll position for directories panel
@@ -30,7 +33,45 @@ type Selector struct {
 	SearchMode   bool              // Indicates if we are in search mode
 	SearchQuery  string            // The current search query
 	OriginalItems []string         // Original items before the search
-	IsSearching  bool              // Indicates if we are in global search mode
+	IsSearching  bool              // Indicates if search results replace the listing
+	// Visual range selection
+	VisualMode   bool              // Indicates if we are in visual mode
+	VisualPanel  int               // Panel where visual mode was started
+	VisualAnchor int               // Position where visual mode was started
+	// Parts of a split bundle waiting to be copied to the clipboard
+	ClipboardParts []string        // Files of the parts
+	ClipboardPart  int             // Index of the next part to copy
+	ClipboardFiles int             // Number of files in the bundle
+	ClipboardWarnings string       // Warnings of the export (redacted secrets, skipped files)
+	// Prompt template dialog
+	PromptMode      bool           // Indicates if the prompt dialog is open
+	PromptTemplates []string       // Names of the available templates
+	PromptTemplate  int            // Index of the chosen template
+	PromptText      string         // Prompt entered by the user
+	// Commands receiving the bundle
+	CommandMode    bool            // Indicates if the command menu is open
+	Commands       []string        // Names of the configured commands
+	CommandIndex   int             // Index of the chosen command
+	PendingCommand *PendingCommand // Command to run in the background, if any
+	// Result pane with the output of a command
+	OutputMode   bool              // Indicates if the result pane is shown
+	OutputTitle  string            // Command, duration and status
+	OutputLines  []string          // Lines of the output
+	OutputScroll int               // First visible line
+	// File to open in the terminal editor, the interface is suspended meanwhile
+	PendingEdit string
+	// Selection watched to keep its bundle up to date, if any
+	Watch *WatchSession
+	// Directories watched to refresh the panels
+	Live           *LiveWatch
+	LiveCursor     string // Directory under the cursor, watched once the cursor rests on it
+	RefreshPending bool   // A change happened while the listing could not be refreshed
+}
+
+// Capturing reports whether the keys are typed into a dialog or pane
+// instead of controlling the panels
+func (s *Selector) Capturing() bool {
+	return s.SearchMode || s.PromptMode || s.CommandMode || s.OutputMode
 }
 
 // Method to update the files of the selected directory
@@ -48,14 +89,8 @@ func (s *Selector) UpdateFilesForCurrentDirectory() {
 		}
 
 		// Update the list of files for the selected directory
-		files, err := os.ReadDir(dir)
+		fileList, err := ListFiles(dir, s.ShowHidden)
 		if err == nil {
-			var fileList []string
-			for _, file := range files {
-				if !file.IsDir() { // Only files
-					fileList = append(fileList, file.Name())
-				}
-			}
 			s.Files = fileList // Update the files
 		} else {
 			s.Files = []string{} // If there is an error, clear the list of files
@@ -64,9 +99,27 @@ func (s *Selector) UpdateFilesForCurrentDirectory() {
 	// We don't update the files when we are in the files panel
 }
 
-// Get the selection key for an item, combining the current directory with the name of the item
+// Set the selection state of a key, remembering when it was selected
+func (s *Selector) SetSelected(key string, selected bool) {
+	if selected && !s.Selection[key] {
+		if s.SelectedAt == nil {
+			s.SelectedAt = make(map[string]int64)
+		}
+		s.SelectedAt[key] = time.Now().UnixNano()
+	} else if !selected {
+		delete(s.SelectedAt, key)
+	}
+	s.Selection[key] = selected
+}
+
+// Get the selection key for an item, combining the current directory with the
+// name of the item. "." is the current directory itself, which may not be the
+// directory catsel was started in.
 func (s *Selector) GetSelectionKey(item string) string {
-	if item == "." || item == ".." {
+	if item == "." {
+		return s.Directory
+	}
+	if item == ".." {
 		return item
 	}
 	return filepath.Join(s.Directory, item)
@@ -125,7 +178,7 @@ func (s *Selector) IsFileSelected(file string) bool {
 func processDirectory(selector *Selector, dirPath string, item string, selectState bool) {
 	// Update the selection state of the current directory
 	selectionKey := selector.GetSelectionKey(item)
-	selector.Selection[selectionKey] = selectState
+	selector.SetSelected(selectionKey, selectState)
 
 	// If the include mode is active, process recursively the subdirectories
 	if selector.IncludeMode {
@@ -141,7 +194,7 @@ func processDirectory(selector *Selector, dirPath string, item string, selectSta
 				} else {
 					// Select files in the current directory
 					fileKey := filepath.Join(dirPath, entry.Name())
-					selector.Selection[fileKey] = selectState
+					selector.SetSelected(fileKey, selectState)
 				}
 			}
 		}
@@ -152,9 +205,60 @@ func processDirectory(selector *Selector, dirPath string, item string, selectSta
 			for _, entry := range entries {
 				if !entry.IsDir() {
 					fileKey := filepath.Join(dirPath, entry.Name())
-					selector.Selection[fileKey] = selectState
+					selector.SetSelected(fileKey, selectState)
 				}
 			}
 		}
 	}
 }
+
+// RefreshListing reads the directories and files again, keeping the
+// cursors on the same entries by name
+func RefreshListing(s *Selector) {
+	current := ""
+	if s.Position >= 0 && s.Position < len(s.Filtered) {
+		current = s.Filtered[s.Position]
+	}
+	currentFile := ""
+	if s.FilePosition >= 0 && s.FilePosition < len(s.Files) {
+		currentFile = s.Files[s.FilePosition]
+	}
+
+	s.Filtered = PrepareDirItems(s.Directory, s.ShowHidden)
+	s.IsSearching = false
+	s.Position = min(s.Position, max(0, len(s.Filtered)-1))
+	for i, item := range s.Filtered {
+		if item == current {
+			s.Position = i
+			break
+		}
+	}
+	keepDirCursorVisible(s, s.Position)
+
+	// Files of the directory under the cursor
+	dir := s.Directory
+	if s.Position < len(s.Filtered) {
+		switch item := s.Filtered[s.Position]; item {
+		case ".":
+		case "..":
+			dir = filepath.Dir(s.Directory)
+		default:
+			dir = filepath.Join(s.Directory, item)
+		}
+	}
+	files, err := ListFiles(dir, s.ShowHidden)
+	if err != nil {
+		files = []string{}
+	}
+	s.Files = files
+	s.FilePosition = min(s.FilePosition, max(0, len(s.Files)-1))
+	for i, file := range s.Files {
+		if file == currentFile {
+			s.FilePosition = i
+			break
+		}
+	}
+	if s.FilePosition < s.FileScroll {
+		s.FileScroll = s.FilePosition
+	}
+}
@@ -49,4 +49,8 @@ var (
 	Background(lipgloss.Color("7")) // blanco
 	Selected = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
 	Scroll = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	// Items inside the visual range
+	Visual = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("0")).
+	Background(lipgloss.Color("3"))
 )
@@ -1,18 +1,25 @@
 package core
 
 import (
+	"catselector/config"
+	"catselector/content"
+	"catselector/export"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
-var rootDirectory string
+var (
+	rootDirectory  string
+	startDirectory string
+	aboveRoot      bool // Whether the navigation may go above the root directory
+)
 
 func OpenTextFile(path string) error {
 	var cmd *exec.Cmd
@@ -38,7 +45,8 @@ func OpenTextFile(path string) error {
 	return cmd.Start()
 }
 
-// GetRootDirectory returns the directory from where the application is executed
+// GetRootDirectory returns the boundary of the navigation, the directory
+// from where the application is executed unless it was set
 func GetRootDirectory() string {
 	if rootDirectory == "" {
 		dir, err := os.Getwd()
@@ -51,24 +59,169 @@ func GetRootDirectory() string {
 	return rootDirectory
 }
 
-func PrepareDirItems(pwd string) []string {
+// SetRootDirectory sets the boundary of the navigation
+func SetRootDirectory(dir string) {
+	rootDirectory = filepath.Clean(dir)
+}
+
+// GetStartDirectory returns the directory shown at start, the root by default
+func GetStartDirectory() string {
+	if startDirectory == "" {
+		return GetRootDirectory()
+	}
+	return startDirectory
+}
+
+// SetStartDirectory sets the directory shown at start
+func SetStartDirectory(dir string) {
+	startDirectory = filepath.Clean(dir)
+}
+
+// AllowAboveRoot lets the navigation go above the root directory
+func AllowAboveRoot(allow bool) {
+	aboveRoot = allow
+}
+
+// Check if the parent of a directory can be entered
+func canGoUp(dir string) bool {
+	if aboveRoot {
+		return filepath.Dir(dir) != dir
+	}
+	return dir != GetRootDirectory()
+}
+
+// Check if a path is a directory or is inside it
+func isInside(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func PrepareDirItems(pwd string, showHidden bool) []string {
 	files, _ := os.ReadDir(pwd)
 	var dirs []string
 	for _, f := range files {
-		if f.IsDir() {
+		if f.IsDir() && !isHiddenEntry(f.Name(), showHidden) {
 			dirs = append(dirs, f.Name())
 		}
 	}
-	sort.Strings(dirs)
+	export.SortNames(pwd, dirs, config.Get().Sort)
 
-	// Add ".." as the first item if we're not in the root directory
-	rootDir := GetRootDirectory()
-	if pwd != rootDir {
+	// Add ".." as the first item if the parent directory can be entered
+	if canGoUp(pwd) {
 		return append([]string{"..", "."}, dirs...)
 	}
 	return append([]string{"."}, dirs...)
 }
 
+// ListFiles returns the names of the visible files (not directories) in a directory
+func ListFiles(dir string, showHidden bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fileList []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !isHiddenEntry(entry.Name(), showHidden) {
+			fileList = append(fileList, entry.Name())
+		}
+	}
+	export.SortNames(dir, fileList, config.Get().Sort)
+	return fileList, nil
+}
+
+// Check if an entry must be hidden according to the hidden files policy
+func isHiddenEntry(name string, showHidden bool) bool {
+	if showHidden {
+		return false
+	}
+	return export.IsHidden(name, config.Get().HiddenPatterns)
+}
+
+// Count the visible files in a directory, optionally including its subdirectories
+func countVisibleFiles(dir string, recursive bool, showHidden bool) int {
+	count := 0
+	if recursive {
+		filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if p != dir && isHiddenEntry(info.Name(), showHidden) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+
+	files, err := ListFiles(dir, showHidden)
+	if err == nil {
+		count = len(files)
+	}
+	return count
+}
+
+// Directory the exported files are named relative to: the current one,
+// or, when files above the root are selected, an ancestor containing them all
+func exportBaseDir(s *Selector) string {
+	rootDir := GetRootDirectory()
+	base := s.Directory
+	for _, path := range getSelectedPaths(s.Selection) {
+		if !filepath.IsAbs(path) || isInside(rootDir, path) {
+			continue
+		}
+		for !isInside(base, path) && base != filepath.Dir(base) {
+			base = filepath.Dir(base)
+		}
+	}
+	return base
+}
+
+// Build the export options for the current state of the selector
+func exportOptions(s *Selector) export.Options {
+	opts := ExportOptions()
+	opts.ShowHidden = s.ShowHidden
+	opts.SelectionOrder = s.SelectedAt
+	return opts
+}
+
+// ExportOptions returns the export options of the configuration
+func ExportOptions() export.Options {
+	return export.Options{
+		ShowHidden:     config.Get().ShowHidden,
+		HiddenPatterns: config.Get().HiddenPatterns,
+		Order:          config.Get().ExportOrder,
+		Sort:           config.Get().Sort,
+		Output:         config.Get().Output,
+		Chunk:          config.Get().Chunk,
+		NonUTF8:        config.Get().NonUTF8,
+		Redact:         config.Get().Redaction,
+		Limit:          config.Get().FileLimit,
+		Minify:         config.Get().Minify,
+		LineNumbers:    config.Get().LineNumbers,
+		Manifest:       config.Get().Manifest,
+		Version:        config.Version,
+	}
+}
+
+// Describe the current sort mode (e.g. "name asc")
+func sortDescription() string {
+	sortOpts := config.Get().Sort
+	mode := sortOpts.Mode
+	if mode == "" {
+		mode = export.SortName
+	}
+	if sortOpts.Descending {
+		return mode + " desc"
+	}
+	return mode + " asc"
+}
+
 // Get the current directory
 func GetCurrentDirectory() string {
 	dir, err := os.Getwd()
@@ -95,74 +248,57 @@ func RenderLeft(text string, isActive bool, isCounter bool, panelWidth int) stri
 
 // IsBinaryFile checks if a file is binary
 func IsBinaryFile(filePath string) bool {
-	file, err := os.Open(filePath)
+	encoding, err := content.SniffFile(filePath)
 	if err != nil {
 		return false
 	}
-	defer file.Close()
-
-	buf := make([]byte, 1024)
-	n, err := file.Read(buf)
-	if err != nil && err != io.EOF {
-		return false
-	}
-
-	nullCount := 0
-	nonPrintableCount := 0
-	for i := 0; i < n; i++ {
-		if buf[i] == 0 {
-			nullCount++
-		}
-		if buf[i] < 32 && buf[i] != '\t' && buf[i] != '\n' && buf[i] != '\r' {
-			nonPrintableCount++
-		}
-	}
-
-	return nullCount > 10 || float64(nonPrintableCount)/float64(n) > 0.3
+	return !encoding.IsText()
 }
 
-// CopyToClipboard copies text to the system clipboard
-func CopyToClipboard(text string) bool {
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		cmd = exec.Command("pbcopy")
-	case "windows":
-		// In Windows, you would need to use another method, like the clipboard package
-		return false
-	default: // Linux and others
-		if _, err := exec.LookPath("xclip"); err == nil {
-			cmd = exec.Command("xclip", "-selection", "clipboard")
-		} else if _, err := exec.LookPath("xsel"); err == nil {
-			cmd = exec.Command("xsel", "--clipboard", "--input")
-		} else if _, err := exec.LookPath("wl-copy"); err == nil {
-			cmd = exec.Command("wl-copy")
-		} else {
-			return false
-		}
+// Describe the warnings of an export for the status bar: secrets redacted
+// and files skipped, e.g. ", 2 secrets redacted, 1 skipped (1 binary)"
+func exportWarnings(result export.Result) string {
+	warnings := ""
+	if result.Redacted > 0 {
+		warnings += fmt.Sprintf(", %d secrets redacted", result.Redacted)
 	}
+	return warnings + skippedDescription(result.Skipped)
+}
 
-	if cmd == nil {
+// Show the error that stopped an export in the status bar. It reports
+// whether there was one.
+func exportFailed(s *Selector, result export.Result) bool {
+	if result.Error == nil {
 		return false
 	}
+	s.StatusMessage = "Export failed: " + result.Error.Error()
+	s.StatusTime = time.Now().Unix()
+	return true
+}
 
-	pipe, err := cmd.StdinPipe()
-	if err != nil {
-		return false
+// Describe the files skipped by an export for the status bar,
+// e.g. ", 3 skipped (2 binary, 1 latin-1)"
+func skippedDescription(skipped []export.Skipped) string {
+	if len(skipped) == 0 {
+		return ""
 	}
 
-	if err := cmd.Start(); err != nil {
-		return false
+	// Count the skipped files by reason, keeping the order of appearance
+	var reasons []string
+	counts := make(map[string]int)
+	for _, entry := range skipped {
+		reason := entry.Reason
+		if counts[reason] == 0 {
+			reasons = append(reasons, reason)
+		}
+		counts[reason]++
 	}
 
-	_, err = pipe.Write([]byte(text))
-	if err != nil {
-		return false
+	var parts []string
+	for _, reason := range reasons {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[reason], reason))
 	}
-
-	pipe.Close()
-	return cmd.Wait() == nil
+	return fmt.Sprintf(", %d skipped (%s)", len(skipped), strings.Join(parts, ", "))
 }
 
 // ShowErrorMessage shows a formatted error message
@@ -243,7 +379,7 @@ func FormatFileSize(size int64) string {
 func SanitizeLine(line string) string {
 	var result strings.Builder
 	for _, r := range line {
-		if r < 32 && r != '\t' && r != '\n' && r != '\r' {
+		if (r < 32 && r != '\t' && r != '\n' && r != '\r') || (r >= 0x7f && r < 0xa0) {
 			result.WriteRune(' ')
 		} else {
 			result.WriteRune(r)
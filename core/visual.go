@@ -0,0 +1,102 @@
+package core
+
+import (
+	"path/filepath"
+)
+
+// Start visual mode anchored at the cursor of the active panel
+func StartVisualMode(s *Selector, position int) {
+	if s.ActivePanel != 1 && s.ActivePanel != 2 {
+		return
+	}
+	s.VisualMode = true
+	s.VisualPanel = s.ActivePanel
+	if s.ActivePanel == 1 {
+		s.VisualAnchor = position
+	} else {
+		s.VisualAnchor = s.FilePosition
+	}
+}
+
+// Get the range of indexes covered by visual mode (both ends included)
+func visualRange(s *Selector, position int) (int, int) {
+	cursor := position
+	if s.VisualPanel == 2 {
+		cursor = s.FilePosition
+	}
+	if cursor < s.VisualAnchor {
+		return cursor, s.VisualAnchor
+	}
+	return s.VisualAnchor, cursor
+}
+
+// InVisualRange checks if an index of the given panel is inside the visual range
+func InVisualRange(s *Selector, panel int, index int) bool {
+	if !s.VisualMode || s.VisualPanel != panel {
+		return false
+	}
+	start, end := visualRange(s, s.Position)
+	return index >= start && index <= end
+}
+
+// Toggle the selection of every item in the visual range at once.
+// If all the items in the range are selected they are deselected,
+// otherwise all of them are selected.
+func toggleVisualRange(s *Selector, position int, items []string) {
+	start, end := visualRange(s, position)
+
+	if s.VisualPanel == 1 {
+		start = max(0, start)
+		end = min(end, len(items)-1)
+
+		allSelected := true
+		for i := start; i <= end; i++ {
+			item := items[i]
+			if item != ".." && item != "." && !s.IsSelected(item) {
+				allSelected = false
+				break
+			}
+		}
+
+		for i := start; i <= end; i++ {
+			item := items[i]
+			if item != ".." && item != "." {
+				dirPath := filepath.Join(s.Directory, item)
+				processDirectoryRecursive(s, dirPath, item, !allSelected)
+			}
+		}
+	} else if s.VisualPanel == 2 {
+		start = max(0, start)
+		end = min(end, len(s.Files)-1)
+
+		allSelected := true
+		for i := start; i <= end; i++ {
+			if !s.Selection[s.GetFileSelectionKey(s.Files[i])] {
+				allSelected = false
+				break
+			}
+		}
+
+		for i := start; i <= end; i++ {
+			fileKey := s.GetFileSelectionKey(s.Files[i])
+			s.SetSelected(fileKey, !allSelected)
+		}
+	}
+}
+
+// Invert the selection of the items listed in the active panel
+func invertSelection(s *Selector, items []string) {
+	if s.ActivePanel == 1 {
+		for _, item := range items {
+			if item != ".." && item != "." {
+				dirPath := filepath.Join(s.Directory, item)
+				processDirectoryRecursive(s, dirPath, item, !s.IsSelected(item))
+			}
+		}
+	} else if s.ActivePanel == 2 {
+		for _, file := range s.Files {
+			fileKey := s.GetFileSelectionKey(file)
+			s.SetSelected(fileKey, !s.Selection[fileKey])
+		}
+	}
+}
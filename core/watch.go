@@ -0,0 +1,112 @@
+package core
+
+import (
+	"catselector/config"
+	"catselector/export"
+	"catselector/watch"
+	"fmt"
+	"time"
+)
+
+// WatchSession regenerates the bundle of a selection when its files change.
+// The watcher only reports the changes, the bundle is regenerated by
+// RegenerateWatch on the interface goroutine.
+type WatchSession struct {
+	Output   string               // Bundle kept up to date
+	Changes  chan int             // Number of changed files waiting for a regeneration
+	stop     chan struct{}        // Closed to stop watching
+	generate func() export.Result // Export of the selection
+}
+
+// Done returns a channel closed when the session is stopped
+func (w *WatchSession) Done() <-chan struct{} {
+	return w.stop
+}
+
+// Stop watching
+func (w *WatchSession) Stop() {
+	close(w.stop)
+}
+
+// ToggleWatch starts or stops watching the current selection. The selection
+// and the export options are taken when watching starts.
+func ToggleWatch(s *Selector) {
+	if s.Watch != nil {
+		s.Watch.Stop()
+		s.StatusMessage = "Stopped watching, bundle left in " + s.Watch.Output
+		s.StatusTime = time.Now().Unix()
+		s.Watch = nil
+		return
+	}
+
+	paths := getSelectedPaths(s.Selection)
+	if len(paths) == 0 {
+		s.StatusMessage = "Select files or directories to watch"
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+
+	// The bundle is always a single file, rewritten in place
+	opts := exportOptions(s)
+	opts.Chunk = export.ChunkOptions{}
+	includeSubdirs := s.IncludeMode
+	rootDir := GetRootDirectory()
+	currentDir := exportBaseDir(s)
+	output := "" // The bundle itself is never exported
+	generate := func() export.Result {
+		return export.GenerateTextFile(paths, []string{output}, includeSubdirs, rootDir, currentDir, opts)
+	}
+
+	result := generate()
+	if exportFailed(s, result) {
+		return
+	}
+	if len(result.Parts) == 0 {
+		s.StatusMessage = "Nothing to watch"
+		s.StatusTime = time.Now().Unix()
+		return
+	}
+	output = result.Parts[0]
+	// The next versions are written next to the bundle, then renamed over it
+	opts.Output = export.RegenerateOptions(output)
+	session := &WatchSession{Output: output, Changes: make(chan int, 1), stop: make(chan struct{}), generate: generate}
+
+	watchOpts := watch.Options{
+		Debounce: watchDebounce(),
+		Skip:     func(name string) bool { return !opts.ShowHidden && export.IsHidden(name, opts.HiddenPatterns) },
+		// Writing the bundle in a watched directory must not trigger a new export
+		Ignore: func(path string) bool { return export.IsOutputFile(path, output) },
+	}
+	method := watch.Watch(paths, watchOpts, func(changed []string) {
+		// A regeneration already waiting covers these changes
+		select {
+		case session.Changes <- len(changed):
+		default:
+		}
+	}, session.stop)
+
+	s.Watch = session
+	s.StatusMessage = fmt.Sprintf("Watching %d paths (%s), bundle in %s", len(paths), method, session.Output)
+	s.StatusTime = time.Now().Unix()
+}
+
+// RegenerateWatch rewrites the bundle of the watch session after changes
+func RegenerateWatch(s *Selector, changed int) {
+	session := s.Watch
+	result := session.generate()
+	if result.Error != nil {
+		s.StatusMessage = "Watch: " + result.Error.Error()
+	} else if len(result.Parts) == 0 {
+		s.StatusMessage = "Watch: error regenerating the bundle"
+	} else if err := export.ReplaceOutput(result, session.Output); err != nil {
+		s.StatusMessage = "Watch: " + err.Error()
+	} else {
+		s.StatusMessage = fmt.Sprintf("Bundle updated at %s (%d changed, %d files)", time.Now().Format("15:04:05"), changed, result.Files)
+	}
+	s.StatusTime = time.Now().Unix()
+}
+
+// Quiet time after a change before the bundle is regenerated
+func watchDebounce() time.Duration {
+	return time.Duration(config.Get().WatchDebounce) * time.Millisecond
+}
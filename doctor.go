@@ -0,0 +1,85 @@
+package main
+
+import (
+	"catselector/config"
+	"catselector/core"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Report the configuration and the clipboard providers that can be used,
+// to find out why copying fails
+func runDoctor(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: catsel doctor")
+		return 2
+	}
+	fmt.Printf("Cat Selector %s (%s/%s)\n\n", config.Version, runtime.GOOS, runtime.GOARCH)
+
+	fmt.Println("Configuration:")
+	path := config.Path()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Printf("  %s (not found, using defaults)\n", path)
+	} else if err := config.Check(path); err != nil {
+		fmt.Printf("  %s (invalid, using defaults: %v)\n", path, err)
+	} else {
+		fmt.Printf("  %s (ok)\n", path)
+	}
+
+	fmt.Println("\nEnvironment:")
+	for _, name := range []string{"TERM", "SSH_TTY", "TMUX", "DISPLAY", "WAYLAND_DISPLAY", "VISUAL", "EDITOR"} {
+		value := os.Getenv(name)
+		if value == "" {
+			value = "(not set)"
+		}
+		fmt.Printf("  %-16s %s\n", name, value)
+	}
+
+	backend := config.Get().Clipboard
+	if backend == "" {
+		backend = config.ClipboardAuto
+	}
+	fmt.Printf("\nClipboard (backend: %s):\n", backend)
+	ordered := core.OrderedClipboardProviders()
+	tried := make(map[string]int)
+	for i, p := range ordered {
+		tried[p.Name()] = i + 1
+	}
+	chosen := ""
+	for _, p := range ordered {
+		if p.Available() == nil {
+			chosen = p.Name()
+			break
+		}
+	}
+	for _, p := range core.ClipboardProviders() {
+		status := "available"
+		if err := p.Available(); err != nil {
+			status = "unavailable: " + err.Error()
+		} else if p.Name() == config.ClipboardOSC52 {
+			status = "available (unverified, a terminal is present)"
+		}
+		order := "  -"
+		if n, ok := tried[p.Name()]; ok {
+			order = fmt.Sprintf("%3d", n)
+		}
+		marker := " "
+		if p.Name() == chosen {
+			marker = "*"
+		}
+		fmt.Printf("  %s %s %-8s %s\n", marker, order, p.Name(), status)
+	}
+	if chosen == "" {
+		fmt.Println("\nNo clipboard provider can be used, set clipboard_order or clipboard in the configuration.")
+		return 1
+	}
+	fmt.Printf("\nCopies use %s (marked with *), the numbers are the order of the providers tried.\n", chosen)
+	switch chosen {
+	case "file":
+		fmt.Println("The text is written to " + core.ClipboardFile())
+	case config.ClipboardOSC52:
+		fmt.Println("OSC 52 cannot be verified: the terminal may ignore the sequence without telling, paste once to check.")
+	}
+	return 0
+}
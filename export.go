@@ -0,0 +1,101 @@
+package main
+
+import (
+	"catselector/config"
+	"catselector/core"
+	"catselector/export"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Export files and directories without the interface and print the paths
+// of the written files
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", export.FormatText, "export format: "+strings.Join(export.Formats, ", "))
+	templateName := flags.String("template", "", "prompt template (name or file) wrapping the text bundle")
+	prompt := flags.String("prompt", "", "prompt passed to the template")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catsel export <path>... [--format text|html|tar.gz|zip] [--template name] [--prompt text]")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before or after the paths
+	var paths []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 2
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) == 0 {
+		flags.Usage()
+		return 2
+	}
+	if !slices.Contains(export.Formats, *format) {
+		fmt.Fprintln(os.Stderr, "Error: unknown format", *format)
+		return 2
+	}
+
+	baseDir := core.GetCurrentDirectory()
+	opts := core.ExportOptions()
+	if *templateName != "" {
+		if *format != export.FormatText {
+			fmt.Fprintln(os.Stderr, "Error: templates only apply to the text format")
+			return 2
+		}
+		text, ok := export.FindTemplates(config.Get().Templates, baseDir)[*templateName]
+		if !ok {
+			data, err := os.ReadFile(*templateName)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error: unknown template", *templateName)
+				return 2
+			}
+			text = string(data)
+		}
+		opts.Template = text
+		opts.Prompt = *prompt
+	}
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(baseDir, path)
+		}
+	}
+
+	var result export.Result
+	switch *format {
+	case export.FormatText:
+		result = export.GenerateCombinedFile(paths, baseDir, opts)
+	case export.FormatHTML:
+		result = export.GenerateCombinedHTMLFile(paths, baseDir, opts)
+	default:
+		result = export.GenerateCombinedArchive(paths, baseDir, *format, opts)
+	}
+	if result.Error != nil {
+		fmt.Fprintln(os.Stderr, "Error:", result.Error)
+		return 1
+	}
+	if len(result.Parts) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: nothing exported")
+		return 1
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Fprintln(os.Stderr, "Skipped:", skipped)
+	}
+	for _, part := range result.Parts {
+		fmt.Println(part)
+	}
+	if result.Manifest != "" {
+		fmt.Println(result.Manifest)
+	}
+	return 0
+}
@@ -0,0 +1,277 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"catselector/content"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the manifest inside an archive
+const archiveManifest = "catsel-manifest.json"
+
+// GenerateArchive generates an archive of the selected files, keeping their
+// paths relative to the current directory and their modes
+func GenerateArchive(selected []string, excluded []string, includeSubdirs bool, initialDir string, currentDir string, format string, opts Options) Result {
+	filesToProcess := collectFiles(selected, excluded, includeSubdirs, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	OrderFiles(filesToProcess, currentDir, opts)
+	return writeArchive(filesToProcess, currentDir, initialDir, format, opts)
+}
+
+// GenerateCombinedArchive generates an archive from a list of files
+func GenerateCombinedArchive(fileList []string, baseDir string, format string, opts Options) Result {
+	filesToProcess := collectFiles(fileList, nil, true, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	OrderFiles(filesToProcess, baseDir, opts)
+	return writeArchive(filesToProcess, baseDir, baseDir, format, opts)
+}
+
+// Entry of an archive
+type archiveEntry struct {
+	Path string      // Path on disk
+	Name string      // Name in the archive
+	Info os.FileInfo // Information of the file (size, mode, time)
+	Data []byte      // Redacted content, nil to store the file as it is
+}
+
+// Write the files to an archive. The files in the deny list are skipped,
+// the secrets of the text files are redacted if enabled, and the other
+// files are stored as they are on disk.
+func writeArchive(files []string, baseDir string, rootDir string, format string, opts Options) Result {
+	if format != FormatTarGz && format != FormatZip {
+		return Result{Error: fmt.Errorf("unknown archive format %q", format)}
+	}
+	outputFile, err := OutputPath(files, rootDir, format, opts)
+	if err != nil {
+		return Result{Error: fmt.Errorf("creating the output directory: %w", err)}
+	}
+
+	// Files outside of the base directory cannot be stored with a relative
+	// path, so the archive is rooted at their common directory
+	root := baseDir
+	for _, filePath := range files {
+		if rel, err := filepath.Rel(baseDir, filePath); err != nil || escapes(rel) {
+			root = commonDir(files)
+			break
+		}
+	}
+
+	r, err := newRedactor(opts.Redact)
+	if err != nil {
+		return Result{Error: err}
+	}
+	var entries []archiveEntry
+	var skipped []Skipped
+	redacted := 0
+	for _, filePath := range files {
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			continue
+		}
+		name := filepath.ToSlash(relPath)
+		if r.denied(name) {
+			skipped = append(skipped, Skipped{Name: name, Reason: "denied"})
+			continue
+		}
+		info, err := os.Stat(filePath)
+		if err != nil || !info.Mode().IsRegular() {
+			skipped = append(skipped, Skipped{Name: name, Reason: "not a regular file"})
+			continue
+		}
+		entry := archiveEntry{Path: filePath, Name: name, Info: info}
+		if opts.Redact.Enabled {
+			data, count, err := redactFile(r, filePath, name)
+			if err != nil {
+				skipped = append(skipped, Skipped{Name: name, Reason: err.Error()})
+				continue
+			}
+			entry.Data = data
+			redacted += count
+		}
+		entries = append(entries, entry)
+	}
+
+	manifest := buildManifest(files, root, skipped, opts)
+	var manifestData []byte
+	if manifest.inSection(opts) {
+		if manifestData, err = manifest.marshal(); err != nil {
+			return Result{Error: err}
+		}
+	}
+
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return Result{Error: fmt.Errorf("creating the archive: %w", err)}
+	}
+	if format == FormatZip {
+		err = writeZip(out, entries, manifestData)
+	} else {
+		err = writeTarGz(out, entries, manifestData)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputFile)
+		return Result{Error: fmt.Errorf("writing the archive: %w", err)}
+	}
+
+	result := Result{Parts: []string{outputFile}, Files: len(entries), Skipped: skipped, Redacted: redacted}
+	if manifest.inSidecar(opts) {
+		result.Manifest, _ = manifest.writeSidecar(outputFile)
+	}
+	return result
+}
+
+// Write the entries to a gzipped tar archive
+func writeTarGz(w io.Writer, entries []archiveEntry, manifest []byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if manifest != nil {
+		header := &tar.Header{Name: archiveManifest, Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(manifest); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		header, err := tar.FileInfoHeader(entry.Info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entry.Name
+		if entry.Data != nil {
+			header.Size = int64(len(entry.Data))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := writeEntry(tw, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Write the entries to a zip archive
+func writeZip(w io.Writer, entries []archiveEntry, manifest []byte) error {
+	zw := zip.NewWriter(w)
+
+	if manifest != nil {
+		fw, err := zw.Create(archiveManifest)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(manifest); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		header, err := zip.FileInfoHeader(entry.Info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.Name
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := writeEntry(fw, entry); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Read a file and redact the secrets of its content if it is text. It
+// returns nil if nothing was redacted, so the file is stored as it is.
+func redactFile(r *redactor, path string, name string) ([]byte, int, error) {
+	encoding, err := content.SniffFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !encoding.IsText() {
+		return nil, 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	text, count := r.redact(name, string(data))
+	if count == 0 {
+		return nil, 0, nil
+	}
+	return []byte(text), count, nil
+}
+
+// Write the content of an entry: the redacted data or the file itself
+func writeEntry(w io.Writer, entry archiveEntry) error {
+	if entry.Data != nil {
+		_, err := w.Write(entry.Data)
+		return err
+	}
+	return copyFile(w, entry.Path, entry.Info.Size())
+}
+
+// Copy the content of a file to an archive entry. The size must match the
+// header, so a file changed in the meantime is an error.
+func copyFile(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(w, io.LimitReader(f, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("%s changed while archiving", path)
+	}
+	return nil
+}
+
+// Longest directory containing all the files
+func commonDir(files []string) string {
+	if len(files) == 0 {
+		return "."
+	}
+	dir := filepath.Dir(files[0])
+	for _, filePath := range files[1:] {
+		for dir != filepath.Dir(dir) {
+			if rel, err := filepath.Rel(dir, filePath); err == nil && !escapes(rel) {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// Check if a relative path goes above its base directory
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
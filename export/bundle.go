@@ -0,0 +1,388 @@
+package export
+
+import (
+	"catselector/content"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Separator written before every file of a bundle
+const fileSeparator = "---------------------------------------------\n"
+
+// Separator written around the header of every part of a split bundle
+const partSeparator = "=============================================\n"
+
+// Space reserved for the header of every part of a split bundle
+const partOverhead = 200
+
+// Result describes the output of an export
+type Result struct {
+	Parts    []string  // Written files, one per part
+	Files    int       // Number of exported files
+	Skipped  []Skipped // Skipped files with the reason
+	Redacted int       // Number of redacted secrets
+	Manifest string    // Sidecar manifest file, if written
+	Error    error     // Error that stopped the export, if it can be reported
+}
+
+// Note of the files whose last line has no line break
+const noFinalNewline = "no final newline"
+
+// ErrNoFiles is the error of an export whose selection holds no file
+var ErrNoFiles = errors.New("no files to export")
+
+// Skipped is a file left out of an export
+type Skipped struct {
+	Name   string // Relative name of the file
+	Reason string // Why it was left out, e.g. "binary"
+}
+
+// String returns the name followed by the reason, e.g. "logo.png (binary)"
+func (s Skipped) String() string {
+	return fmt.Sprintf("%s (%s)", s.Name, s.Reason)
+}
+
+// Count the secrets redacted in the sections
+func countRedacted(sections []section) int {
+	count := 0
+	for _, sec := range sections {
+		count += sec.Redacted
+	}
+	return count
+}
+
+// ChunkOptions limits the size of every part of a bundle
+type ChunkOptions struct {
+	MaxBytes  int64 `json:"max_bytes"`  // Maximum bytes per part, 0 for no limit
+	MaxTokens int64 `json:"max_tokens"` // Maximum tokens per part (estimated as 4 bytes each), 0 for no limit
+}
+
+// Limit returns the maximum size in bytes of a part, 0 if there is no limit
+func (c ChunkOptions) Limit() int64 {
+	limit := c.MaxBytes
+	if c.MaxTokens > 0 && (limit <= 0 || c.MaxTokens*4 < limit) {
+		limit = c.MaxTokens * 4
+	}
+	return limit
+}
+
+// Section of a bundle with the content of one file
+type section struct {
+	Name     string    // Relative name of the file
+	Content  string    // Rendered content of the file, ending with a new line
+	Lines    []srcLine // Lines of the content with their original numbers
+	Notes    []string  // Notes about the content shown in the file header
+	Redacted int       // Number of redacted secrets
+	Size     int64     // Size of the file on disk
+}
+
+// Piece of a part: a whole section or a fragment of a large one
+type piece struct {
+	Name string // Name shown in the part header, empty for the footer
+	Text string // Rendered text
+}
+
+// Read a file and build its section, named relative to the base directory.
+// If the file is skipped it also returns the reason.
+func readSection(filePath string, baseDir string, opts Options, r *redactor) (section, string) {
+	relPath, err := filepath.Rel(baseDir, filePath)
+	if err != nil {
+		relPath = filePath
+	}
+	sec := section{Name: filepath.ToSlash(relPath)}
+	if info, err := os.Stat(filePath); err == nil {
+		sec.Size = info.Size()
+	}
+
+	// Files in the deny list are never read
+	if r.denied(sec.Name) {
+		return sec, "denied"
+	}
+
+	text, encoding, err := content.ReadFile(filePath)
+	if err != nil {
+		sec.Content = fmt.Sprintf("[Error reading file: %s]\n", err.Error())
+		return sec, ""
+	}
+
+	// Binary files are never exported, other encodings depend on the policy
+	if !encoding.IsText() {
+		return sec, string(encoding)
+	}
+	if !encoding.IsUTF8() {
+		if opts.NonUTF8 == EncodingSkip {
+			return sec, string(encoding)
+		}
+		sec.Notes = append(sec.Notes, "transcoded from "+string(encoding))
+	}
+
+	// Number of lines of the original file, shown in the header. The content
+	// always ends with a line break, a missing one is noted for unpack.
+	sec.Notes = append([]string{plural(len(splitLines(text)), "line")}, sec.Notes...)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		sec.Notes = append(sec.Notes, noFinalNewline)
+	}
+
+	// Replace the secrets before the content leaves the file
+	text, sec.Redacted = r.redact(sec.Name, text)
+	if sec.Redacted > 0 {
+		sec.Notes = append(sec.Notes, fmt.Sprintf("%d secrets redacted", sec.Redacted))
+	}
+
+	lines := splitLines(text)
+
+	// Make the content smaller
+	lines, note := minify(sec.Name, lines, opts.Minify)
+	if note != "" {
+		sec.Notes = append(sec.Notes, note)
+	}
+
+	// Apply the per-file limits
+	lines, note, reason := applyLimit(lines, opts.Limit)
+	if reason != "" {
+		return sec, reason
+	}
+	if note != "" {
+		sec.Notes = append(sec.Notes, note)
+	}
+
+	sec.Lines = lines
+	sec.Content = renderLines(lines, opts.LineNumbers)
+	return sec, ""
+}
+
+// Read the sections of the files to export, collecting the skipped files
+func readSections(files []string, baseDir string, opts Options) ([]section, []Skipped, error) {
+	r, err := newRedactor(opts.Redact)
+	if err != nil {
+		return nil, nil, err
+	}
+	sections := make([]section, 0, len(files))
+	var skipped []Skipped
+	for _, filePath := range files {
+		sec, reason := readSection(filePath, baseDir, opts, r)
+		if reason != "" {
+			skipped = append(skipped, Skipped{Name: sec.Name, Reason: reason})
+			continue
+		}
+		sections = append(sections, sec)
+	}
+	return sections, skipped, nil
+}
+
+// Render a section in the text format. The label marks the fragments of a
+// file split across parts, e.g. " (part 1/2)".
+func renderSection(sec section, text string, label string) string {
+	name := sec.Name
+	notes := ""
+	if len(sec.Notes) > 0 {
+		notes = " [" + strings.Join(sec.Notes, "; ") + "]"
+	}
+
+	var b strings.Builder
+	b.WriteString(fileSeparator)
+	b.WriteString(fmt.Sprintf("// File %s%s%s\n", name, label, notes))
+	b.WriteString(text)
+	b.WriteString(fmt.Sprintf("// End of file %s%s\n\n", name, label))
+	return b.String()
+}
+
+// Write the sections of a bundle to one or more text files. The skipped
+// files are listed in a footer at the end of the bundle and the manifest,
+// if any, at the beginning or in a sidecar file.
+func writeTextBundle(sections []section, skipped []Skipped, manifest *Manifest, files []string, rootDir string, opts Options) Result {
+	if opts.Template != "" {
+		return writePromptBundle(sections, skipped, manifest, files, rootDir, opts)
+	}
+
+	outputFile, err := OutputPath(files, rootDir, "txt", opts)
+	if err != nil {
+		return Result{Error: fmt.Errorf("creating the output directory: %w", err)}
+	}
+
+	var leading []piece
+	if manifest.inSection(opts) {
+		leading = append(leading, piece{Text: manifest.render()})
+	}
+	parts := splitParts(leading, sections, opts.Chunk.Limit())
+	if len(skipped) > 0 {
+		footer := piece{Text: skippedFooter(skipped)}
+		if len(parts) == 0 {
+			parts = append(parts, nil)
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], footer)
+	}
+
+	// A bundle that fits in one part is written without part header
+	if len(parts) <= 1 {
+		var b strings.Builder
+		for _, p := range parts {
+			for _, pc := range p {
+				b.WriteString(pc.Text)
+			}
+		}
+		if err := os.WriteFile(outputFile, []byte(b.String()), 0644); err != nil {
+			return Result{Error: fmt.Errorf("writing the bundle: %w", err)}
+		}
+		result := Result{Parts: []string{outputFile}, Files: len(sections), Skipped: skipped, Redacted: countRedacted(sections)}
+		if manifest.inSidecar(opts) {
+			result.Manifest, _ = manifest.writeSidecar(outputFile)
+		}
+		return result
+	}
+
+	result := Result{Files: len(sections), Skipped: skipped, Redacted: countRedacted(sections)}
+	base := strings.TrimSuffix(outputFile, filepath.Ext(outputFile))
+	for i, p := range parts {
+		var b strings.Builder
+		b.WriteString(partHeader(p, i+1, len(parts)))
+		for _, pc := range p {
+			b.WriteString(pc.Text)
+		}
+
+		partFile := fmt.Sprintf("%s-part%dof%d%s", base, i+1, len(parts), filepath.Ext(outputFile))
+		if err := os.WriteFile(partFile, []byte(b.String()), 0644); err != nil {
+			// An incomplete bundle is not left behind
+			os.Remove(partFile)
+			for _, written := range result.Parts {
+				os.Remove(written)
+			}
+			return Result{Error: fmt.Errorf("writing part %d/%d: %w", i+1, len(parts), err)}
+		}
+		result.Parts = append(result.Parts, partFile)
+	}
+	if manifest.inSidecar(opts) {
+		result.Manifest, _ = manifest.writeSidecar(outputFile)
+	}
+	return result
+}
+
+// Render the manifest header of a part, listing the files it contains
+func partHeader(pieces []piece, index int, total int) string {
+	var b strings.Builder
+	b.WriteString(partSeparator)
+	b.WriteString(fmt.Sprintf("// Part %d/%d\n", index, total))
+	b.WriteString("// Files in this part:\n")
+	for _, pc := range pieces {
+		if pc.Name != "" {
+			b.WriteString(fmt.Sprintf("//   %s\n", pc.Name))
+		}
+	}
+	b.WriteString(partSeparator)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Render the footer listing the skipped files
+func skippedFooter(skipped []Skipped) string {
+	var b strings.Builder
+	b.WriteString(partSeparator)
+	b.WriteString("// Skipped files:\n")
+	for _, entry := range skipped {
+		b.WriteString(fmt.Sprintf("//   %s\n", entry))
+	}
+	b.WriteString(partSeparator)
+	return b.String()
+}
+
+// Split the sections in parts no larger than the limit, after the leading
+// pieces (e.g. the manifest). A file is only split when it does not fit in
+// a part by itself.
+func splitParts(leading []piece, sections []section, limit int64) [][]piece {
+	var parts [][]piece
+	current := leading
+	var size int64
+	for _, pc := range leading {
+		size += int64(len(pc.Text))
+	}
+
+	for _, sec := range sections {
+		for _, pc := range splitSection(sec, limit) {
+			// Every piece also takes a line in the part header
+			cost := int64(len(pc.Text) + len(pc.Name) + 6)
+			if limit > 0 && len(current) > 0 && size+cost > limit-partOverhead {
+				parts = append(parts, current)
+				current = nil
+				size = 0
+			}
+			current = append(current, pc)
+			size += cost
+		}
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// Split a section on line boundaries if it does not fit in a part by itself
+func splitSection(sec section, limit int64) []piece {
+	text := renderSection(sec, sec.Content, "")
+	budget := limit - partOverhead
+	if limit <= 0 || int64(len(text)+len(sec.Name)+6) <= budget {
+		return []piece{{Name: sec.Name, Text: text}}
+	}
+
+	// Space left for the content once the markers are written
+	contentBudget := budget - int64(len(text)-len(sec.Content)) - 2*int64(len(" (part 00/00)")) - int64(len(sec.Name)) - 20
+	if contentBudget < 1 {
+		contentBudget = 1
+	}
+
+	// Group the lines in fragments within the budget. A line longer than
+	// the budget is cut, and its fragment ends with a line break that is
+	// not part of the file.
+	var fragments []string
+	var continues []bool
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(sec.Content, "\n") {
+		if line == "" {
+			continue
+		}
+		if b.Len() > 0 && int64(b.Len()+len(line)) > contentBudget {
+			fragments = append(fragments, b.String())
+			continues = append(continues, false)
+			b.Reset()
+		}
+		for int64(len(line)) > contentBudget {
+			cut := cutIndex(line, int(contentBudget))
+			fragments = append(fragments, line[:cut]+"\n")
+			continues = append(continues, true)
+			line = line[cut:]
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		fragments = append(fragments, b.String())
+		continues = append(continues, false)
+	}
+
+	pieces := make([]piece, len(fragments))
+	for i, fragment := range fragments {
+		label := fmt.Sprintf(" (part %d/%d)", i+1, len(fragments))
+		if continues[i] {
+			label = fmt.Sprintf(" (part %d/%d%s)", i+1, len(fragments), lineContinues)
+		}
+		pieces[i] = piece{
+			Name: sec.Name + label,
+			Text: renderSection(sec, fragment, label),
+		}
+	}
+	return pieces
+}
+
+// Label of a fragment cut inside a line, which continues in the next one
+const lineContinues = ", line continues"
+
+// Index where a line is cut to fit in n bytes, on a character boundary
+func cutIndex(line string, n int) int {
+	cut := n
+	for cut > 1 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return cut
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChunkLimit(t *testing.T) {
+	tests := []struct {
+		opts ChunkOptions
+		want int64
+	}{
+		{ChunkOptions{}, 0},
+		{ChunkOptions{MaxBytes: 5000}, 5000},
+		{ChunkOptions{MaxTokens: 1000}, 4000},
+		{ChunkOptions{MaxBytes: 5000, MaxTokens: 1000}, 4000},
+		{ChunkOptions{MaxBytes: 3000, MaxTokens: 1000}, 3000},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.Limit(); got != tt.want {
+			t.Errorf("%+v.Limit() = %d, want %d", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestCutIndex(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		want int
+	}{
+		{"abcdef", 3, 3},
+		{"aé", 2, 1},  // "é" takes bytes 1 and 2
+		{"aéb", 3, 3}, // The cut falls after "é"
+		{"日本語", 4, 3},
+		{"日本語", 2, 1}, // Never an empty cut
+	}
+	for _, tt := range tests {
+		if got := cutIndex(tt.line, tt.n); got != tt.want {
+			t.Errorf("cutIndex(%q, %d) = %d, want %d", tt.line, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSplitParts(t *testing.T) {
+	small := section{Name: "small.txt", Content: "hello\n"}
+	var lines strings.Builder
+	for i := 0; i < 100; i++ {
+		lines.WriteString("a line of the large file\n")
+	}
+	large := section{Name: "large.txt", Content: lines.String()}
+
+	tests := []struct {
+		name     string
+		sections []section
+		limit    int64
+		split    bool
+	}{
+		{"no limit", []section{small, large}, 0, false},
+		{"fits", []section{small, small}, 1000, false},
+		{"large file split", []section{small, large}, 1000, true},
+	}
+	for _, tt := range tests {
+		parts := splitParts(nil, tt.sections, tt.limit)
+		if split := len(parts) > 1; split != tt.split {
+			t.Errorf("%s: %d parts, want split %v", tt.name, len(parts), tt.split)
+		}
+		for i, p := range parts {
+			size := len(partHeader(p, i+1, len(parts)))
+			for _, pc := range p {
+				size += len(pc.Text)
+			}
+			if tt.limit > 0 && int64(size) > tt.limit {
+				t.Errorf("%s: part %d has %d bytes, limit %d", tt.name, i+1, size, tt.limit)
+			}
+			// Only the file too large for a part is cut
+			for _, pc := range p {
+				if strings.HasPrefix(pc.Name, "small.txt ") {
+					t.Errorf("%s: small.txt was split", tt.name)
+				}
+			}
+		}
+	}
+}
+
+func TestSplitLongLinesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"long.txt":  strings.Repeat("0123456789", 300) + "\nshort\n" + strings.Repeat("ñandú ", 400) + "\n",
+		"small.txt": "small\n",
+	}
+	var paths []string
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	opts := Options{Output: OutputOptions{Dir: t.TempDir()}, Chunk: ChunkOptions{MaxBytes: 1000}}
+	result := GenerateCombinedFile(paths, dir, opts)
+	if result.Error != nil || len(result.Parts) < 2 {
+		t.Fatalf("export not split: %+v", result)
+	}
+	var bundle strings.Builder
+	for _, part := range result.Parts {
+		data, err := os.ReadFile(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1000 {
+			t.Errorf("%s has %d bytes, limit 1000", filepath.Base(part), len(data))
+		}
+		bundle.Write(data)
+	}
+
+	unpacked, err := ParseBundle(bundle.String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpacked) != len(files) {
+		t.Errorf("%d files unpacked, want %d", len(unpacked), len(files))
+	}
+	for _, file := range unpacked {
+		if file.Content != files[file.Name] {
+			t.Errorf("%s unpacked with %d bytes, want %d", file.Name, len(file.Content), len(files[file.Name]))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Maximum size of the comparison table, larger files are only summarized
+const maxDiffCells = 4000000
+
+// Lines of context around every change
+const diffContext = 3
+
+// Diff returns a unified diff between two contents, empty if they are equal
+func Diff(name string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	oldLines := splitDiffLines(oldText)
+	newLines := splitDiffLines(newText)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("--- a/%s\n+++ b/%s\n", name, name))
+	if len(oldLines)*len(newLines) > maxDiffCells {
+		b.WriteString(fmt.Sprintf("@@ files differ (%d -> %d lines) @@\n", len(oldLines), len(newLines)))
+		return b.String()
+	}
+
+	// Longest common subsequence of the lines
+	n, m := len(oldLines), len(newLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Edit script: ' ' kept, '-' removed, '+' added
+	type edit struct {
+		op   byte
+		text string
+		oldN int // Line number in the old content
+		newN int // Line number in the new content
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && oldLines[i] == newLines[j]:
+			edits = append(edits, edit{' ', oldLines[i], i, j})
+			i++
+			j++
+		case i < n && (j >= m || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', oldLines[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', newLines[j], i, j})
+			j++
+		}
+	}
+
+	// Group the changes in hunks with context
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		start := max(0, k-diffContext)
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			// Stop when the next change is far enough
+			next := end
+			for next < len(edits) && edits[next].op == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*diffContext {
+				end = min(len(edits), end+diffContext)
+				break
+			}
+			end = next
+		}
+
+		oldCount, newCount := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		// An empty range starts at the line before it, e.g. -0,0 for a new file
+		oldStart, newStart := edits[start].oldN+1, edits[start].newN+1
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		b.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+		for _, e := range edits[start:end] {
+			b.WriteString(string(e.op) + e.text + "\n")
+		}
+		k = end
+	}
+	return b.String()
+}
+
+// Split a content in lines for the diff
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
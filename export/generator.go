@@ -1,28 +1,63 @@
 package export
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
-	"time"
 )
 
-// GenerateTextFile generates a text file with the content of the selected files
-func GenerateTextFile(selected []string, excluded []string, includeSubdirs bool, initialDir string, currentDir string) string {
-	// Create a unique hash based on the current time and the selected files
-	selectedCopy := make([]string, len(selected))
-	copy(selectedCopy, selected)
-	sort.Strings(selectedCopy)
+// GenerateTextFile generates a text file with the content of the selected files,
+// split in several parts if it exceeds the chunk limit
+func GenerateTextFile(selected []string, excluded []string, includeSubdirs bool, initialDir string, currentDir string, opts Options) Result {
+	filesToProcess := collectFiles(selected, excluded, includeSubdirs, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	// Sort the files so that the export is deterministic
+	OrderFiles(filesToProcess, currentDir, opts)
+
+	// Read the content of each file
+	sections, skipped, err := readSections(filesToProcess, currentDir, opts)
+	if err != nil {
+		return Result{Error: err}
+	}
+	manifest := buildManifest(filesToProcess, currentDir, skipped, opts)
+
+	return writeTextBundle(sections, skipped, manifest, filesToProcess, initialDir, opts)
+}
+
+// GenerateCombinedFile generates a combined file from a list of files
+func GenerateCombinedFile(fileList []string, baseDir string, opts Options) Result {
+	filesToProcess := collectFiles(fileList, nil, true, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	// Sort the files so that the export is deterministic
+	OrderFiles(filesToProcess, baseDir, opts)
+
+	// Read the content of each file
+	sections, skipped, err := readSections(filesToProcess, baseDir, opts)
+	if err != nil {
+		return Result{Error: err}
+	}
+	manifest := buildManifest(filesToProcess, baseDir, skipped, opts)
 
-	hashInput := fmt.Sprintf("%d%v", time.Now().Unix(), selectedCopy)
-	hasher := md5.New()
-	hasher.Write([]byte(hashInput))
-	hashValue := hex.EncodeToString(hasher.Sum(nil))[:8]
+	return writeTextBundle(sections, skipped, manifest, filesToProcess, baseDir, opts)
+}
 
+// SelectedFiles returns the files an export of the selected paths would
+// contain, in the export order
+func SelectedFiles(selected []string, excluded []string, includeSubdirs bool, currentDir string, opts Options) []string {
+	files := collectFiles(selected, excluded, includeSubdirs, opts)
+	OrderFiles(files, currentDir, opts)
+	return files
+}
+
+// Collect the files to export from the selected paths. Directories are
+// walked recursively if includeSubdirs is set, otherwise only their top
+// level is used. Hidden entries below a selected directory are skipped.
+func collectFiles(selected []string, excluded []string, includeSubdirs bool, opts Options) []string {
 	filesToProcess := []string{}
 
 	// Create a map for quick exclusion search
@@ -31,7 +66,6 @@ func GenerateTextFile(selected []string, excluded []string, includeSubdirs bool,
 		excludedMap[path] = true
 	}
 
-	// Collect files to process
 	for _, path := range selected {
 		if excludedMap[path] {
 			continue
@@ -42,164 +76,63 @@ func GenerateTextFile(selected []string, excluded []string, includeSubdirs bool,
 			continue
 		}
 
-		if info.IsDir() {
-			if includeSubdirs {
-				// Process the directory recursively
-				filepath.Walk(path, func(filePath string, fileInfo os.FileInfo, err error) error {
-					if err != nil {
-						return nil // Continue with the next file
-					}
-
-					if !fileInfo.IsDir() && !excludedMap[filePath] {
-						filesToProcess = append(filesToProcess, filePath)
-					}
-					return nil
-				})
-			} else {
-				// Process only the top level of the directory
-				files, err := os.ReadDir(path)
-				if err == nil {
-					for _, file := range files {
-						filePath := filepath.Join(path, file.Name())
-
-						fileInfo, err := file.Info()
-						if err != nil {
-							continue
-						}
-
-						if !fileInfo.IsDir() && !excludedMap[filePath] {
-							filesToProcess = append(filesToProcess, filePath)
-						}
-					}
-				}
-			}
-		} else if !info.IsDir() {
+		if !info.IsDir() {
 			filesToProcess = append(filesToProcess, path)
-		}
-	}
-
-	if len(filesToProcess) == 0 {
-		return ""
-	}
-
-	// Create the output file
-	outputFile := filepath.Join(initialDir, fmt.Sprintf("cs_%s.txt", hashValue))
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return ""
-	}
-	defer file.Close()
-
-	// Write the content of each file
-	for _, filePath := range filesToProcess {
-		relPath, err := filepath.Rel(currentDir, filePath)
-		if err != nil {
-			relPath = filePath
-		}
-		relativeName := filepath.ToSlash(relPath)
-
-		file.WriteString("---------------------------------------------\n")
-		file.WriteString(fmt.Sprintf("// File %s\n", relativeName))
-
-		content, err := os.ReadFile(filePath)
-		if err == nil {
-			file.Write(content)
-			// Ensure the content ends with a new line
-			if len(content) > 0 && content[len(content)-1] != '\n' {
-				file.WriteString("\n")
-			}
-		} else if strings.Contains(err.Error(), "invalid UTF-8") {
-			file.WriteString("[Binary file or incompatible encoding]\n")
-		} else {
-			file.WriteString(fmt.Sprintf("[Error reading file: %s]\n", err.Error()))
-		}
-
-		file.WriteString(fmt.Sprintf("// End of file %s\n\n", relativeName))
-	}
-
-	return outputFile
-}
-
-// GenerateCombinedFile generates a combined file from a list of files
-func GenerateCombinedFile(fileList []string, baseDir string) string {
-	if len(fileList) == 0 {
-		return ""
-	}
-
-	// Create a unique hash based on the current time and the selected files
-	fileListCopy := make([]string, len(fileList))
-	copy(fileListCopy, fileList)
-	sort.Strings(fileListCopy)
-
-	hashInput := fmt.Sprintf("%d%v", time.Now().Unix(), fileListCopy)
-	hasher := md5.New()
-	hasher.Write([]byte(hashInput))
-	hashValue := hex.EncodeToString(hasher.Sum(nil))[:8]
-
-	filesToProcess := []string{}
-
-	// Collect files to process
-	for _, path := range fileList {
-		info, err := os.Stat(path)
-		if err != nil {
 			continue
 		}
 
-		if info.IsDir() {
+		if includeSubdirs {
 			// Process the directory recursively
 			filepath.Walk(path, func(filePath string, fileInfo os.FileInfo, err error) error {
 				if err != nil {
 					return nil // Continue with the next file
 				}
 
-				if !fileInfo.IsDir() {
+				// Skip hidden entries below the selected directory
+				if filePath != path && opts.skip(fileInfo.Name()) {
+					if fileInfo.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if !fileInfo.IsDir() && !excludedMap[filePath] {
 					filesToProcess = append(filesToProcess, filePath)
 				}
 				return nil
 			})
-		} else {
-			filesToProcess = append(filesToProcess, path)
+			continue
 		}
-	}
-
-	if len(filesToProcess) == 0 {
-		return ""
-	}
-
-	// Create the output file
-	outputFile := filepath.Join(baseDir, fmt.Sprintf("cs_%s.txt", hashValue))
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return ""
-	}
-	defer file.Close()
 
-	// Write the content of each file
-	for _, filePath := range filesToProcess {
-		relPath, err := filepath.Rel(baseDir, filePath)
+		// Process only the top level of the directory
+		files, err := os.ReadDir(path)
 		if err != nil {
-			relPath = filePath
+			continue
 		}
-		relativeName := filepath.ToSlash(relPath)
+		for _, file := range files {
+			if opts.skip(file.Name()) {
+				continue
+			}
+			filePath := filepath.Join(path, file.Name())
 
-		file.WriteString("---------------------------------------------\n")
-		file.WriteString(fmt.Sprintf("// File %s\n", relativeName))
+			fileInfo, err := file.Info()
+			if err != nil {
+				continue
+			}
 
-		content, err := os.ReadFile(filePath)
-		if err == nil {
-			file.Write(content)
-			// Ensure the content ends with a new line
-			if len(content) > 0 && content[len(content)-1] != '\n' {
-				file.WriteString("\n")
+			if !fileInfo.IsDir() && !excludedMap[filePath] {
+				filesToProcess = append(filesToProcess, filePath)
 			}
-		} else if strings.Contains(err.Error(), "invalid UTF-8") {
-			file.WriteString("[Binary file or incompatible encoding]\n")
-		} else {
-			file.WriteString(fmt.Sprintf("[Error reading file: %s]\n", err.Error()))
 		}
-
-		file.WriteString(fmt.Sprintf("// End of file %s\n\n", relativeName))
 	}
-
-	return outputFile
+	// A file may be both selected and inside a selected directory
+	seen := make(map[string]bool)
+	unique := filesToProcess[:0]
+	for _, filePath := range filesToProcess {
+		if !seen[filePath] {
+			seen[filePath] = true
+			unique = append(unique, filePath)
+		}
+	}
+	return unique
 }
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"html"
+	"strings"
+)
+
+// Keywords highlighted in the code of any language
+var keywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "case": true, "catch": true,
+	"chan": true, "class": true, "const": true, "continue": true, "def": true, "default": true,
+	"defer": true, "del": true, "do": true, "elif": true, "else": true, "enum": true,
+	"except": true, "export": true, "extends": true, "false": true, "False": true, "finally": true,
+	"fn": true, "for": true, "from": true, "func": true, "function": true, "go": true,
+	"if": true, "impl": true, "import": true, "in": true, "interface": true, "let": true,
+	"map": true, "match": true, "mod": true, "mut": true, "new": true, "nil": true,
+	"None": true, "null": true, "package": true, "private": true, "protected": true, "pub": true,
+	"public": true, "range": true, "return": true, "select": true, "self": true, "static": true,
+	"struct": true, "switch": true, "this": true, "throw": true, "trait": true, "true": true,
+	"True": true, "try": true, "type": true, "use": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true,
+}
+
+// Highlight the lines of a file as HTML, with spans for the comments
+// ("c"), strings ("s"), numbers ("n") and keywords ("k"). Files of an
+// unknown language are only escaped.
+func highlightLines(name string, lines []srcLine) []string {
+	syn, known := syntaxFor(name)
+	out := make([]string, len(lines))
+	inBlock := false
+	var quote byte // Quote of the open string
+
+	for li, l := range lines {
+		text := strings.TrimRight(l.Text, "\r")
+		if !known || l.Num == 0 {
+			out[li] = html.EscapeString(text)
+			continue
+		}
+
+		var b strings.Builder
+		span := func(class string, s string) {
+			b.WriteString(`<span class="` + class + `">` + html.EscapeString(s) + `</span>`)
+		}
+
+		open := -1 // Start of a string opened in this line
+		for i := 0; i < len(text); {
+			switch {
+			case inBlock:
+				end := strings.Index(text[i:], syn.BlockEnd)
+				if end < 0 {
+					span("c", text[i:])
+					i = len(text)
+					continue
+				}
+				span("c", text[i:i+end+len(syn.BlockEnd)])
+				i += end + len(syn.BlockEnd)
+				inBlock = false
+			case quote != 0:
+				start := i
+				if open >= 0 {
+					start, open = open, -1
+				}
+				j := i
+				for j < len(text) {
+					if text[j] == '\\' && strings.IndexByte(syn.RawQuotes, quote) < 0 {
+						j += 2
+						continue
+					}
+					if text[j] == quote {
+						quote = 0
+						j++
+						break
+					}
+					j++
+				}
+				j = min(j, len(text))
+				span("s", text[start:j])
+				i = j
+			case syn.BlockStart != "" && strings.HasPrefix(text[i:], syn.BlockStart):
+				inBlock = true
+				span("c", syn.BlockStart)
+				i += len(syn.BlockStart)
+			case lineCommentAt(text, i, syn):
+				span("c", text[i:])
+				i = len(text)
+			case strings.IndexByte(syn.Quotes, text[i]) >= 0:
+				quote = text[i]
+				open = i
+				i++
+			case isWordByte(text[i]):
+				j := i
+				for j < len(text) && isWordByte(text[j]) {
+					j++
+				}
+				word := text[i:j]
+				if word[0] >= '0' && word[0] <= '9' {
+					span("n", word)
+				} else if keywords[word] {
+					span("k", word)
+				} else {
+					b.WriteString(html.EscapeString(word))
+				}
+				i = j
+			default:
+				b.WriteString(html.EscapeString(text[i : i+1]))
+				i++
+			}
+		}
+
+		if open >= 0 {
+			span("s", text[open:])
+		}
+
+		// Only some strings continue in the next line
+		if quote != 0 && strings.IndexByte(syn.MultiLine, quote) < 0 {
+			quote = 0
+		}
+		out[li] = b.String()
+	}
+	return out
+}
+
+// Check if a byte is part of an identifier or a number
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
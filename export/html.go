@@ -0,0 +1,291 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Data of the HTML report template
+type htmlReport struct {
+	Title     string
+	Generated string
+	Tool      string
+	TotalSize string
+	Tree      template.HTML
+	Files     []htmlFile
+	Skipped   []Skipped
+	Manifest  string
+}
+
+// File of the HTML report
+type htmlFile struct {
+	ID    string
+	Name  string
+	Size  string
+	Notes string
+	Lines []htmlLine
+}
+
+// Line of a file of the HTML report, Num is 0 for the omitted lines marker
+type htmlLine struct {
+	Num  int
+	Code template.HTML
+}
+
+// GenerateHTMLFile generates a self-contained HTML report of the selected files
+func GenerateHTMLFile(selected []string, excluded []string, includeSubdirs bool, initialDir string, currentDir string, opts Options) Result {
+	filesToProcess := collectFiles(selected, excluded, includeSubdirs, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	OrderFiles(filesToProcess, currentDir, opts)
+	sections, skipped, err := readSections(filesToProcess, currentDir, opts)
+	if err != nil {
+		return Result{Error: err}
+	}
+	manifest := buildManifest(filesToProcess, currentDir, skipped, opts)
+
+	return writeHTMLReport(sections, skipped, manifest, filesToProcess, initialDir, opts)
+}
+
+// GenerateCombinedHTMLFile generates an HTML report from a list of files
+func GenerateCombinedHTMLFile(fileList []string, baseDir string, opts Options) Result {
+	filesToProcess := collectFiles(fileList, nil, true, opts)
+	if len(filesToProcess) == 0 {
+		return Result{Error: ErrNoFiles}
+	}
+
+	OrderFiles(filesToProcess, baseDir, opts)
+	sections, skipped, err := readSections(filesToProcess, baseDir, opts)
+	if err != nil {
+		return Result{Error: err}
+	}
+	manifest := buildManifest(filesToProcess, baseDir, skipped, opts)
+
+	return writeHTMLReport(sections, skipped, manifest, filesToProcess, baseDir, opts)
+}
+
+// Write the sections to a single HTML file with a file tree, highlighted
+// code with line anchors and a search box. The report is never split.
+func writeHTMLReport(sections []section, skipped []Skipped, manifest *Manifest, files []string, rootDir string, opts Options) Result {
+	outputFile, err := OutputPath(files, rootDir, FormatHTML, opts)
+	if err != nil {
+		return Result{Error: fmt.Errorf("creating the output directory: %w", err)}
+	}
+
+	report := htmlReport{
+		Title:     filepath.Base(rootDir),
+		Generated: time.Now().Format("2006-01-02 15:04"),
+		Tool:      strings.TrimSpace("catsel " + opts.Version),
+		Skipped:   skipped,
+	}
+	if manifest.inSection(opts) {
+		report.Manifest = manifest.render()
+	}
+
+	var total int64
+	for i, sec := range sections {
+		file := htmlFile{
+			ID:    fmt.Sprintf("f%d", i+1),
+			Name:  sec.Name,
+			Size:  formatSize(sec.Size),
+			Notes: strings.Join(sec.Notes, "; "),
+		}
+		total += sec.Size
+
+		// Sections without lines only hold an error message
+		lines := sec.Lines
+		if lines == nil && sec.Content != "" {
+			lines = []srcLine{{Num: 0, Text: strings.TrimSuffix(sec.Content, "\n")}}
+		}
+		for j, code := range highlightLines(sec.Name, lines) {
+			file.Lines = append(file.Lines, htmlLine{Num: lines[j].Num, Code: template.HTML(code)})
+		}
+		report.Files = append(report.Files, file)
+	}
+	report.TotalSize = formatSize(total)
+	report.Tree = template.HTML(renderTree(report.Files))
+
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return Result{Error: fmt.Errorf("creating the report: %w", err)}
+	}
+	err = htmlTemplate.Execute(out, report)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputFile)
+		return Result{Error: fmt.Errorf("writing the report: %w", err)}
+	}
+
+	result := Result{Parts: []string{outputFile}, Files: len(sections), Skipped: skipped, Redacted: countRedacted(sections)}
+	if manifest.inSidecar(opts) {
+		result.Manifest, _ = manifest.writeSidecar(outputFile)
+	}
+	return result
+}
+
+// Directory of the file tree of the report
+type treeNode struct {
+	Dirs  map[string]*treeNode
+	Files []htmlFile
+}
+
+// Render the file tree as nested lists, directories first and by name
+func renderTree(files []htmlFile) string {
+	root := &treeNode{Dirs: make(map[string]*treeNode)}
+	for _, file := range files {
+		node := root
+		parts := strings.Split(file.Name, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			if node.Dirs[dir] == nil {
+				node.Dirs[dir] = &treeNode{Dirs: make(map[string]*treeNode)}
+			}
+			node = node.Dirs[dir]
+		}
+		node.Files = append(node.Files, file)
+	}
+
+	var b strings.Builder
+	var render func(node *treeNode)
+	render = func(node *treeNode) {
+		b.WriteString("<ul>")
+		dirs := make([]string, 0, len(node.Dirs))
+		for dir := range node.Dirs {
+			dirs = append(dirs, dir)
+		}
+		sort.Slice(dirs, func(i, j int) bool { return NaturalCompare(dirs[i], dirs[j]) < 0 })
+		for _, dir := range dirs {
+			b.WriteString(`<li class="dir"><details open><summary>` + html.EscapeString(dir) + "/</summary>")
+			render(node.Dirs[dir])
+			b.WriteString("</details></li>")
+		}
+		sort.SliceStable(node.Files, func(i, j int) bool { return NaturalCompare(node.Files[i].Name, node.Files[j].Name) < 0 })
+		for _, file := range node.Files {
+			fmt.Fprintf(&b, `<li class="file" data-file="%s"><a href="#%s">%s</a> <span class="size">%s</span></li>`,
+				file.ID, file.ID, html.EscapeString(filepath.Base(file.Name)), file.Size)
+		}
+		b.WriteString("</ul>")
+	}
+	render(root)
+	return b.String()
+}
+
+// Format a size in bytes for people (e.g. "1.2 KB")
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
+
+// Template of the HTML report, with inline style and script
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - catsel report</title>
+<style>
+* { box-sizing: border-box; }
+body { margin: 0; font-family: -apple-system, "Segoe UI", Roboto, sans-serif; color: #1f2328; background: #fff; }
+#sidebar { position: fixed; top: 0; bottom: 0; left: 0; width: 300px; overflow: auto; padding: 12px; background: #f6f8fa; border-right: 1px solid #d0d7de; font-size: 14px; }
+#sidebar h1 { font-size: 16px; margin: 0 0 4px; }
+#sidebar .meta { color: #656d76; font-size: 12px; margin-bottom: 8px; }
+#search { width: 100%; padding: 6px 8px; margin-bottom: 4px; border: 1px solid #d0d7de; border-radius: 6px; font-size: 14px; }
+#count { color: #656d76; font-size: 12px; margin-bottom: 8px; }
+#sidebar ul { list-style: none; margin: 0; padding-left: 14px; }
+#sidebar > ul { padding-left: 0; }
+#sidebar summary { cursor: pointer; font-weight: 600; }
+#sidebar a { color: #0969da; text-decoration: none; }
+#sidebar a:hover { text-decoration: underline; }
+.size { color: #656d76; font-size: 12px; }
+main { margin-left: 300px; padding: 16px 24px; }
+section { margin-bottom: 24px; border: 1px solid #d0d7de; border-radius: 6px; overflow: hidden; }
+section h2 { margin: 0; padding: 8px 12px; font-size: 14px; background: #f6f8fa; border-bottom: 1px solid #d0d7de; font-family: ui-monospace, Menlo, Consolas, monospace; }
+section h2 .notes { font-weight: normal; color: #656d76; }
+.code { overflow-x: auto; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 13px; line-height: 1.45; }
+.l { display: flex; white-space: pre; }
+.l:target, .l.hit { background: #fff8c5; }
+.l .ln { flex: none; width: 56px; padding-right: 12px; text-align: right; color: #8c959f; text-decoration: none; user-select: none; }
+.l .ln:hover { color: #0969da; }
+.l.gap { color: #656d76; font-style: italic; }
+.c { color: #6e7781; font-style: italic; }
+.s { color: #0a3069; }
+.n { color: #0550ae; }
+.k { color: #cf222e; font-weight: 600; }
+pre.block { margin: 0; padding: 12px; overflow-x: auto; font-size: 13px; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<nav id="sidebar">
+<h1>{{.Title}}</h1>
+<div class="meta">{{len .Files}} files, {{.TotalSize}} &middot; {{.Generated}} &middot; {{.Tool}}</div>
+<input id="search" type="search" placeholder="Search files and content" autocomplete="off">
+<div id="count"></div>
+{{.Tree}}
+</nav>
+<main>
+{{if .Manifest}}<section><h2>Manifest</h2><pre class="block">{{.Manifest}}</pre></section>
+{{end}}{{range .Files}}{{$id := .ID}}<section id="{{.ID}}" data-name="{{.Name}}">
+<h2>{{.Name}} <span class="notes">{{.Size}}{{if .Notes}} &middot; {{.Notes}}{{end}}</span></h2>
+<div class="code">{{range .Lines}}{{if .Num}}<div class="l" id="{{$id}}-L{{.Num}}"><a class="ln" href="#{{$id}}-L{{.Num}}">{{.Num}}</a><span>{{.Code}}</span></div>{{else}}<div class="l gap"><span class="ln"></span><span>{{.Code}}</span></div>{{end}}
+{{end}}</div>
+</section>
+{{end}}{{if .Skipped}}<section><h2>Skipped files</h2><pre class="block">{{range .Skipped}}{{.}}
+{{end}}</pre></section>
+{{end}}</main>
+<script>
+(function () {
+  var input = document.getElementById("search");
+  var count = document.getElementById("count");
+  var sections = Array.prototype.slice.call(document.querySelectorAll("main section[data-name]"));
+  var texts = sections.map(function (s) { return s.textContent.toLowerCase(); });
+
+  function search() {
+    var q = input.value.trim().toLowerCase();
+    var shown = 0;
+    sections.forEach(function (s, i) {
+      var match = q === "" || s.dataset.name.toLowerCase().indexOf(q) >= 0 || texts[i].indexOf(q) >= 0;
+      s.classList.toggle("hidden", !match);
+      var item = document.querySelector('#sidebar li[data-file="' + s.id + '"]');
+      if (item) item.classList.toggle("hidden", !match);
+      if (match) shown++;
+      s.querySelectorAll(".l").forEach(function (l) {
+        l.classList.toggle("hit", q.length >= 2 && l.textContent.toLowerCase().indexOf(q) >= 0);
+      });
+    });
+    document.querySelectorAll("#sidebar li.dir").forEach(function (d) {
+      d.classList.toggle("hidden", d.querySelector("li.file:not(.hidden)") === null);
+    });
+    count.textContent = q === "" ? "" : shown + " of " + sections.length + " files match";
+  }
+
+  input.addEventListener("input", search);
+  input.addEventListener("keydown", function (e) {
+    if (e.key !== "Enter") return;
+    var hit = document.querySelector("main .l.hit") || document.querySelector("main section[data-name]:not(.hidden)");
+    if (hit) hit.scrollIntoView({ block: "center" });
+  });
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Strategies for the files exceeding the per-file limits
+const (
+	LimitSkip     = "skip"      // Leave the file out of the bundle
+	LimitHead     = "head"      // Keep the first lines
+	LimitHeadTail = "head_tail" // Keep the first and the last lines
+	LimitSummary  = "summary"   // Keep only the declarations
+)
+
+// LimitOptions limits the size of every exported file
+type LimitOptions struct {
+	MaxBytes  int64  `json:"max_bytes"`  // Maximum size of a file, 0 for no limit
+	MaxLines  int    `json:"max_lines"`  // Maximum lines of a file, 0 for no limit
+	Strategy  string `json:"strategy"`   // What to do with the larger files
+	HeadLines int    `json:"head_lines"` // Lines kept from the beginning
+	TailLines int    `json:"tail_lines"` // Lines kept from the end (head_tail)
+}
+
+// Lines that declare something in the common languages
+var declaration = regexp.MustCompile(`^\s*(?:export\s+|pub(?:\([a-z]+\))?\s+|public\s+|private\s+|protected\s+|static\s+|async\s+|abstract\s+)*(?:func|type|class|def|interface|struct|enum|trait|impl|fn|module|package|const|var|let|function)\b`)
+
+// Marker written instead of the omitted lines
+func elision(omitted int) srcLine {
+	return srcLine{Text: fmt.Sprintf("... [%d lines omitted] ...", omitted)}
+}
+
+// Apply the per-file limits to the lines of a file. It returns the lines
+// to export and a note describing the truncation, or the reason if the file
+// must be skipped.
+func applyLimit(lines []srcLine, opts LimitOptions) ([]srcLine, string, string) {
+	size := int64(len(joinLines(lines)))
+	exceeds := (opts.MaxBytes > 0 && size > opts.MaxBytes) ||
+		(opts.MaxLines > 0 && len(lines) > opts.MaxLines)
+	if !exceeds {
+		return lines, "", ""
+	}
+
+	head := opts.HeadLines
+	if head <= 0 {
+		head = opts.MaxLines
+	}
+	if head <= 0 {
+		head = 100
+	}
+	tail := opts.TailLines
+
+	kept, note := lines, ""
+	switch opts.Strategy {
+	case LimitSkip:
+		return nil, "", "too large"
+	case LimitSummary:
+		kept = nil
+		for _, l := range lines {
+			if declaration.MatchString(l.Text) {
+				kept = append(kept, l)
+			}
+		}
+		note = fmt.Sprintf("summary: %d declarations of %d lines", len(kept), len(lines))
+	case LimitHead:
+		if head < len(lines) {
+			kept = append(lines[:head:head], elision(len(lines)-head))
+			note = fmt.Sprintf("truncated: first %d of %d lines", head, len(lines))
+		}
+	default:
+		if head+tail < len(lines) {
+			kept = append(lines[:head:head], elision(len(lines)-head-tail))
+			kept = append(kept, lines[len(lines)-tail:]...)
+			note = fmt.Sprintf("truncated: first %d and last %d of %d lines", head, tail, len(lines))
+		}
+	}
+
+	// Few but long lines (e.g. minified files) are cut to the byte limit
+	if opts.MaxBytes > 0 && int64(len(joinLines(kept))) > opts.MaxBytes {
+		byteNote := fmt.Sprintf("first %d of %d bytes", opts.MaxBytes, size)
+		kept = truncateBytes(kept, opts.MaxBytes)
+		if note == "" {
+			note = "truncated: " + byteNote
+		} else {
+			note += ", " + byteNote
+		}
+	}
+	return kept, note, ""
+}
+
+// Keep the beginning of the lines within max bytes, cutting the first line
+// that does not fit on a character boundary. The kept lines are followed
+// by a marker with the number of omitted bytes. The markers of omitted
+// lines (without number) are kept if they fit but never counted as omitted.
+func truncateBytes(lines []srcLine, max int64) []srcLine {
+	var kept []srcLine
+	var size, omitted int64
+	for _, l := range lines {
+		lineSize := int64(len(l.Text) + 1)
+		switch {
+		case l.Num == 0:
+			if omitted == 0 && size+lineSize <= max {
+				kept = append(kept, l)
+				size += lineSize
+			}
+		case omitted == 0 && size+lineSize <= max:
+			kept = append(kept, l)
+			size += lineSize
+		case omitted == 0 && max-size-1 > 0:
+			cut := cutIndex(l.Text, int(max-size-1))
+			kept = append(kept, srcLine{Num: l.Num, Text: l.Text[:cut]})
+			omitted += int64(len(l.Text) - cut)
+		default:
+			omitted += lineSize
+		}
+	}
+	return append(kept, srcLine{Text: fmt.Sprintf("... [%d bytes omitted] ...", omitted)})
+}
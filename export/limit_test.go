@@ -0,0 +1,67 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Lines "line 1" to "line n"
+func numberedLines(n int) []srcLine {
+	lines := make([]srcLine, n)
+	for i := range lines {
+		lines[i] = srcLine{Num: i + 1, Text: fmt.Sprintf("line %d", i+1)}
+	}
+	return lines
+}
+
+func TestApplyLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []srcLine
+		opts   LimitOptions
+		want   string // Kept lines joined by "|"
+		note   string
+		reason string
+	}{
+		{"under the limit", numberedLines(3), LimitOptions{MaxLines: 5, Strategy: LimitHead}, "line 1|line 2|line 3", "", ""},
+		{"skip", numberedLines(10), LimitOptions{MaxLines: 5, Strategy: LimitSkip}, "", "", "too large"},
+		{"head", numberedLines(10), LimitOptions{MaxLines: 5, Strategy: LimitHead, HeadLines: 2},
+			"line 1|line 2|... [8 lines omitted] ...", "truncated: first 2 of 10 lines", ""},
+		{"head defaults to max lines", numberedLines(10), LimitOptions{MaxLines: 3, Strategy: LimitHead},
+			"line 1|line 2|line 3|... [7 lines omitted] ...", "truncated: first 3 of 10 lines", ""},
+		{"head and tail", numberedLines(10), LimitOptions{MaxLines: 5, Strategy: LimitHeadTail, HeadLines: 2, TailLines: 2},
+			"line 1|line 2|... [6 lines omitted] ...|line 9|line 10", "truncated: first 2 and last 2 of 10 lines", ""},
+		{"summary", []srcLine{{1, "package p"}, {2, ""}, {3, "func A() {"}, {4, "\treturn"}, {5, "}"}, {6, "type T struct{}"}},
+			LimitOptions{MaxLines: 2, Strategy: LimitSummary}, "package p|func A() {|type T struct{}", "summary: 3 declarations of 6 lines", ""},
+		{"bytes of a single long line", []srcLine{{1, strings.Repeat("x", 100)}}, LimitOptions{MaxBytes: 11, Strategy: LimitHead},
+			"xxxxxxxxxx|... [90 bytes omitted] ...", "truncated: first 11 of 101 bytes", ""},
+		{"bytes after the lines", []srcLine{{1, "abc"}, {2, strings.Repeat("y", 50)}, {3, "z"}}, LimitOptions{MaxBytes: 10, Strategy: LimitHeadTail},
+			"abc|yyyyy|... [47 bytes omitted] ...", "truncated: first 10 of 57 bytes", ""},
+		{"bytes on a character boundary", []srcLine{{1, strings.Repeat("é", 10)}}, LimitOptions{MaxBytes: 6, Strategy: LimitHead},
+			"éé|... [16 bytes omitted] ...", "truncated: first 6 of 21 bytes", ""},
+		{"lines then bytes", []srcLine{{1, strings.Repeat("a", 40)}, {2, "b"}, {3, "c"}}, LimitOptions{MaxLines: 2, MaxBytes: 20, Strategy: LimitHead, HeadLines: 1},
+			strings.Repeat("a", 19) + "|... [21 bytes omitted] ...", "truncated: first 1 of 3 lines, first 20 of 45 bytes", ""},
+	}
+	for _, tt := range tests {
+		kept, note, reason := applyLimit(tt.lines, tt.opts)
+		var texts []string
+		for _, l := range kept {
+			texts = append(texts, l.Text)
+		}
+		if got := strings.Join(texts, "|"); got != tt.want || note != tt.note || reason != tt.reason {
+			t.Errorf("%s: applyLimit = %q, %q, %q, want %q, %q, %q", tt.name, got, note, reason, tt.want, tt.note, tt.reason)
+		}
+	}
+}
+
+func TestApplyLimitKeepsNumbers(t *testing.T) {
+	kept, _, _ := applyLimit(numberedLines(10), LimitOptions{MaxLines: 5, Strategy: LimitHeadTail, HeadLines: 1, TailLines: 1})
+	var nums []int
+	for _, l := range kept {
+		nums = append(nums, l.Num)
+	}
+	if fmt.Sprint(nums) != "[1 0 10]" {
+		t.Errorf("numbers of the kept lines = %v, want [1 0 10]", nums)
+	}
+}
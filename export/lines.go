@@ -0,0 +1,81 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineNumberOptions controls the line numbers of the exported files
+type LineNumberOptions struct {
+	Enabled   bool   `json:"enabled"`   // Prefix every line with its original number
+	Separator string `json:"separator"` // Text between the number and the line
+}
+
+// Line of an exported file with its number in the original file.
+// Lines added by the exporter (e.g. elision markers) have number 0.
+type srcLine struct {
+	Num  int
+	Text string // Text without the line break
+}
+
+// Split a text into numbered lines
+func splitLines(text string) []srcLine {
+	if text == "" {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	lines := make([]srcLine, len(parts))
+	for i, part := range parts {
+		lines[i] = srcLine{Num: i + 1, Text: part}
+	}
+	return lines
+}
+
+// Format a count with a noun in the singular or the plural, e.g. "1 line"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Join numbered lines into a text ending with a line break
+func joinLines(lines []srcLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Render numbered lines, prefixed with their original numbers if enabled.
+// Numbers are right-aligned to the widest number of the file.
+func renderLines(lines []srcLine, opts LineNumberOptions) string {
+	if !opts.Enabled {
+		return joinLines(lines)
+	}
+
+	maxNum := 0
+	for _, l := range lines {
+		if l.Num > maxNum {
+			maxNum = l.Num
+		}
+	}
+	width := len(strconv.Itoa(maxNum))
+
+	var b strings.Builder
+	for _, l := range lines {
+		if l.Num > 0 {
+			b.WriteString(fmt.Sprintf("%*d", width, l.Num))
+		} else {
+			// Lines added by the exporter have no number
+			b.WriteString(strings.Repeat(" ", width))
+		}
+		b.WriteString(opts.Separator)
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
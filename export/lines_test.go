@@ -0,0 +1,57 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"\n", []string{""}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a"}},
+		{"a\n\nb\n", []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		lines := splitLines(tt.text)
+		var got []string
+		for i, l := range lines {
+			if l.Num != i+1 {
+				t.Errorf("splitLines(%q): line %d numbered %d", tt.text, i+1, l.Num)
+			}
+			got = append(got, l.Text)
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for n, want := range map[int]string{0: "0 lines", 1: "1 line", 2: "2 lines"} {
+		if got := plural(n, "line"); got != want {
+			t.Errorf("plural(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRenderLines(t *testing.T) {
+	lines := []srcLine{{9, "a"}, {0, "..."}, {10, "b"}}
+	tests := []struct {
+		opts LineNumberOptions
+		want string
+	}{
+		{LineNumberOptions{}, "a\n...\nb\n"},
+		{LineNumberOptions{Enabled: true, Separator: " | "}, " 9 | a\n   | ...\n10 | b\n"},
+		{LineNumberOptions{Enabled: true, Separator: ": "}, " 9: a\n  : ...\n10: b\n"},
+	}
+	for _, tt := range tests {
+		if got := renderLines(lines, tt.opts); got != tt.want {
+			t.Errorf("renderLines(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
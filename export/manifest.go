@@ -0,0 +1,174 @@
+package export
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest modes
+const (
+	ManifestNone    = "none"    // No manifest
+	ManifestSection = "section" // Manifest section at the beginning of the bundle
+	ManifestSidecar = "sidecar" // Manifest in a .manifest.json file next to the bundle
+	ManifestBoth    = "both"    // Section and sidecar file
+)
+
+// Manifest describes an export so it can be checked against a tree later
+type Manifest struct {
+	Generated time.Time      `json:"generated"` // Time of the export
+	Root      string         `json:"root"`      // Directory the paths are relative to
+	Tool      string         `json:"tool"`      // Name and version of the tool
+	Set       string         `json:"set"`       // Name of the selection set
+	Files     []ManifestFile `json:"files"`     // Exported files
+}
+
+// ManifestFile describes one file of an export
+type ManifestFile struct {
+	Path    string    `json:"path"`               // Relative path
+	Size    int64     `json:"size"`               // Size in bytes
+	Lines   int       `json:"lines"`              // Number of lines
+	ModTime time.Time `json:"mtime"`              // Modification time
+	SHA256  string    `json:"sha256"`             // SHA-256 of the content
+	GitBlob string    `json:"git_blob,omitempty"` // Git blob hash, for files inside a git repository
+	Skipped string    `json:"skipped,omitempty"`  // Reason if the file was not exported
+}
+
+// Build the manifest of the files of an export. The checksums are computed
+// on the files as they are on disk, before any transform.
+func buildManifest(files []string, baseDir string, skipped []Skipped, opts Options) *Manifest {
+	if opts.Manifest == "" || opts.Manifest == ManifestNone {
+		return nil
+	}
+
+	// Reason of every skipped file by name
+	reasons := make(map[string]string)
+	for _, entry := range skipped {
+		reasons[entry.Name] = entry.Reason
+	}
+
+	setName := opts.SetName
+	if setName == "" {
+		setName = "selection"
+	}
+	m := &Manifest{
+		Generated: time.Now().UTC().Truncate(time.Second),
+		Root:      baseDir,
+		Tool:      strings.TrimSpace("catsel " + opts.Version),
+		Set:       setName,
+	}
+
+	for _, filePath := range files {
+		relPath, err := filepath.Rel(baseDir, filePath)
+		if err != nil {
+			relPath = filePath
+		}
+		entry := ManifestFile{Path: filepath.ToSlash(relPath)}
+		entry.Skipped = reasons[entry.Path]
+
+		info, err := os.Stat(filePath)
+		if err == nil {
+			entry.Size = info.Size()
+			entry.ModTime = info.ModTime().UTC().Truncate(time.Second)
+		}
+		data, err := os.ReadFile(filePath)
+		if err == nil {
+			sum := sha256.Sum256(data)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+			entry.Lines = len(splitLines(string(data)))
+			if insideGitRepository(filePath) {
+				entry.GitBlob = gitBlobHash(data)
+			}
+		}
+		m.Files = append(m.Files, entry)
+	}
+	return m
+}
+
+// Check if a file is inside a git working tree
+func insideGitRepository(path string) bool {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+// Compute the hash git gives to a file content ("git hash-object")
+func gitBlobHash(data []byte) string {
+	hasher := sha1.New()
+	hasher.Write([]byte(fmt.Sprintf("blob %d\x00", len(data))))
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Check if the manifest must be written as a section of the bundle
+func (m *Manifest) inSection(opts Options) bool {
+	return m != nil && (opts.Manifest == ManifestSection || opts.Manifest == ManifestBoth)
+}
+
+// Check if the manifest must be written to a sidecar file
+func (m *Manifest) inSidecar(opts Options) bool {
+	return m != nil && (opts.Manifest == ManifestSidecar || opts.Manifest == ManifestBoth)
+}
+
+// Render the manifest as a comment section of the text format
+func (m *Manifest) render() string {
+	var b strings.Builder
+	b.WriteString(partSeparator)
+	b.WriteString("// Manifest\n")
+	b.WriteString(fmt.Sprintf("// Generated: %s\n", m.Generated.Format(time.RFC3339)))
+	b.WriteString(fmt.Sprintf("// Root: %s\n", m.Root))
+	b.WriteString(fmt.Sprintf("// Tool: %s\n", m.Tool))
+	b.WriteString(fmt.Sprintf("// Set: %s\n", m.Set))
+	b.WriteString("// Files (path, size, lines, mtime, sha256, git blob):\n")
+	for _, f := range m.Files {
+		gitBlob := f.GitBlob
+		if gitBlob == "" {
+			gitBlob = "-"
+		}
+		line := fmt.Sprintf("//   %s  %d  %d  %s  %s  %s", f.Path, f.Size, f.Lines,
+			f.ModTime.Format(time.RFC3339), f.SHA256, gitBlob)
+		if f.Skipped != "" {
+			line += fmt.Sprintf("  (skipped: %s)", f.Skipped)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(partSeparator)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Encode the manifest as indented JSON
+func (m *Manifest) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Write the manifest as JSON next to an output file
+func (m *Manifest) writeSidecar(outputFile string) (string, error) {
+	data, err := m.marshal()
+	if err != nil {
+		return "", err
+	}
+	path := SidecarPath(outputFile)
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// SidecarPath returns the path of the manifest written next to an output file
+func SidecarPath(outputFile string) string {
+	base, _ := splitExt(outputFile)
+	return base + ".manifest.json"
+}
@@ -0,0 +1,348 @@
+package export
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MinifyOptions controls the transforms applied to the content of the
+// exported files to make them smaller
+type MinifyOptions struct {
+	StripComments      bool `json:"strip_comments"`       // Remove line and block comments
+	CollapseBlankLines bool `json:"collapse_blank_lines"` // Keep at most one blank line in a row
+	DropLicenseHeaders bool `json:"drop_license_headers"` // Remove the license comment at the top of the files
+	TrimTrailingSpace  bool `json:"trim_trailing_space"`  // Remove the spaces at the end of the lines
+	GoOutline          bool `json:"go_outline"`           // Keep only the signatures of the Go functions
+}
+
+// Comment and string syntax of a language
+type syntax struct {
+	LineComment []string // Tokens starting a line comment
+	NeedsSpace  bool     // Line comments must start the line or follow a space (e.g. "#" in shell)
+	BlockStart  string   // Token starting a block comment
+	BlockEnd    string   // Token ending a block comment
+	Quotes      string   // Characters delimiting strings
+	MultiLine   string   // Quotes of strings that can span several lines
+	RawQuotes   string   // Quotes of strings without escape sequences
+}
+
+var (
+	cSyntax    = syntax{LineComment: []string{"//"}, BlockStart: "/*", BlockEnd: "*/", Quotes: `"'`}
+	goSyntax   = syntax{LineComment: []string{"//"}, BlockStart: "/*", BlockEnd: "*/", Quotes: "\"'`", MultiLine: "`", RawQuotes: "`"}
+	jsSyntax   = syntax{LineComment: []string{"//"}, BlockStart: "/*", BlockEnd: "*/", Quotes: "\"'`", MultiLine: "`"}
+	rustSyntax = syntax{LineComment: []string{"//"}, BlockStart: "/*", BlockEnd: "*/", Quotes: `"`}
+	hashSyntax = syntax{LineComment: []string{"#"}, NeedsSpace: true, Quotes: `"'`}
+	dashSyntax = syntax{LineComment: []string{"--"}, BlockStart: "/*", BlockEnd: "*/", Quotes: `'`}
+	cssSyntax  = syntax{BlockStart: "/*", BlockEnd: "*/", Quotes: `"'`}
+	xmlSyntax  = syntax{BlockStart: "<!--", BlockEnd: "-->"}
+)
+
+// Syntax of the languages by file extension
+var syntaxes = map[string]syntax{
+	".go": goSyntax,
+	".c":  cSyntax, ".h": cSyntax, ".cc": cSyntax, ".cpp": cSyntax, ".hpp": cSyntax,
+	".java": cSyntax, ".cs": cSyntax, ".kt": cSyntax, ".scala": cSyntax, ".swift": cSyntax,
+	".php": cSyntax, ".dart": cSyntax, ".proto": cSyntax,
+	".js": jsSyntax, ".jsx": jsSyntax, ".ts": jsSyntax, ".tsx": jsSyntax, ".mjs": jsSyntax, ".cjs": jsSyntax,
+	".rs": rustSyntax,
+	".py": hashSyntax, ".rb": hashSyntax, ".sh": hashSyntax, ".bash": hashSyntax, ".zsh": hashSyntax,
+	".yml": hashSyntax, ".yaml": hashSyntax, ".toml": hashSyntax, ".pl": hashSyntax, ".r": hashSyntax,
+	".conf": hashSyntax, ".cfg": hashSyntax, ".mk": hashSyntax,
+	".sql": dashSyntax, ".lua": dashSyntax, ".hs": dashSyntax,
+	".css": cssSyntax, ".scss": cssSyntax, ".less": cssSyntax,
+	".html": xmlSyntax, ".htm": xmlSyntax, ".xml": xmlSyntax, ".svg": xmlSyntax, ".vue": xmlSyntax,
+}
+
+// Files without extension that use "#" comments
+var hashFiles = map[string]bool{"Makefile": true, "Dockerfile": true, "Gemfile": true, "Rakefile": true}
+
+// Comments that are license headers
+var licenseText = regexp.MustCompile(`(?i)copyright|license|spdx-license-identifier`)
+
+// Get the comment syntax of a file
+func syntaxFor(name string) (syntax, bool) {
+	if hashFiles[filepath.Base(name)] {
+		return hashSyntax, true
+	}
+	syn, ok := syntaxes[strings.ToLower(filepath.Ext(name))]
+	return syn, ok
+}
+
+// Apply the minification transforms to the lines of a file. It returns the
+// transformed lines and a note describing the applied transforms.
+func minify(name string, lines []srcLine, opts MinifyOptions) ([]srcLine, string) {
+	var applied []string
+	syn, known := syntaxFor(name)
+
+	if opts.GoOutline && strings.HasSuffix(name, ".go") {
+		if outlined, ok := goOutline(lines); ok {
+			lines = outlined
+			applied = append(applied, "outline")
+		}
+	}
+	if opts.DropLicenseHeaders && known {
+		if stripped, ok := dropLicenseHeader(lines, syn); ok {
+			lines = stripped
+			applied = append(applied, "license header")
+		}
+	}
+	if opts.StripComments && known {
+		if stripped, ok := stripComments(lines, syn); ok {
+			lines = stripped
+			applied = append(applied, "comments")
+		}
+	}
+	if opts.TrimTrailingSpace {
+		changed := false
+		for i := range lines {
+			trimmed := strings.TrimRight(lines[i].Text, " \t\r")
+			if trimmed != lines[i].Text {
+				lines[i].Text = trimmed
+				changed = true
+			}
+		}
+		if changed {
+			applied = append(applied, "trailing spaces")
+		}
+	}
+	if opts.CollapseBlankLines {
+		var collapsed []srcLine
+		for i, l := range lines {
+			if strings.TrimSpace(l.Text) == "" && i > 0 && strings.TrimSpace(lines[i-1].Text) == "" {
+				continue
+			}
+			collapsed = append(collapsed, l)
+		}
+		if len(collapsed) != len(lines) {
+			lines = collapsed
+			applied = append(applied, "blank lines")
+		}
+	}
+
+	if len(applied) == 0 {
+		return lines, ""
+	}
+	return lines, "minified: " + strings.Join(applied, ", ")
+}
+
+// Check if a line comment starts at the given position of a line
+func lineCommentAt(text string, i int, syn syntax) bool {
+	for _, token := range syn.LineComment {
+		if !strings.HasPrefix(text[i:], token) {
+			continue
+		}
+		if syn.NeedsSpace && i > 0 && text[i-1] != ' ' && text[i-1] != '\t' {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Remove the comments of the lines, respecting the strings. Lines that only
+// contained a comment are removed. It returns false if nothing changed.
+func stripComments(lines []srcLine, syn syntax) ([]srcLine, bool) {
+	var out []srcLine
+	inBlock := false
+	var quote byte // Quote of the open string
+	changed := false
+
+	for _, l := range lines {
+		text := l.Text
+		var b strings.Builder
+		removed := false
+
+		// Keep the shebang
+		if l.Num == 1 && strings.HasPrefix(text, "#!") {
+			out = append(out, l)
+			continue
+		}
+
+		for i := 0; i < len(text); {
+			if inBlock {
+				end := strings.Index(text[i:], syn.BlockEnd)
+				removed = true
+				if end < 0 {
+					break
+				}
+				i += end + len(syn.BlockEnd)
+				inBlock = false
+				continue
+			}
+
+			c := text[i]
+			if quote != 0 {
+				b.WriteByte(c)
+				if c == '\\' && strings.IndexByte(syn.RawQuotes, quote) < 0 && i+1 < len(text) {
+					b.WriteByte(text[i+1])
+					i += 2
+					continue
+				}
+				if c == quote {
+					quote = 0
+				}
+				i++
+				continue
+			}
+
+			if syn.BlockStart != "" && strings.HasPrefix(text[i:], syn.BlockStart) {
+				inBlock = true
+				removed = true
+				i += len(syn.BlockStart)
+				continue
+			}
+			if lineCommentAt(text, i, syn) {
+				removed = true
+				break
+			}
+			if strings.IndexByte(syn.Quotes, c) >= 0 {
+				quote = c
+			}
+			b.WriteByte(c)
+			i++
+		}
+
+		// Only some strings continue in the next line
+		if quote != 0 && strings.IndexByte(syn.MultiLine, quote) < 0 {
+			quote = 0
+		}
+
+		if !removed {
+			out = append(out, l)
+			continue
+		}
+		changed = true
+		result := strings.TrimRight(b.String(), " \t")
+		if strings.TrimSpace(result) == "" && strings.TrimSpace(text) != "" {
+			continue
+		}
+		out = append(out, srcLine{Num: l.Num, Text: result})
+	}
+	return out, changed
+}
+
+// Remove the comment block at the top of a file if it is a license header.
+// It returns false if there is no license header.
+func dropLicenseHeader(lines []srcLine, syn syntax) ([]srcLine, bool) {
+	start := 0
+	// Skip the shebang and the leading blank lines
+	if len(lines) > 0 && strings.HasPrefix(lines[0].Text, "#!") {
+		start = 1
+	}
+	for start < len(lines) && strings.TrimSpace(lines[start].Text) == "" {
+		start++
+	}
+
+	end := start
+	inBlock := false
+	for end < len(lines) {
+		text := strings.TrimSpace(lines[end].Text)
+		if inBlock {
+			end++
+			if strings.Contains(text, syn.BlockEnd) {
+				inBlock = false
+			}
+			continue
+		}
+		if syn.BlockStart != "" && strings.HasPrefix(text, syn.BlockStart) {
+			end++
+			inBlock = !strings.Contains(text[len(syn.BlockStart):], syn.BlockEnd)
+			continue
+		}
+		if text != "" && lineCommentAt(text, 0, syn) {
+			end++
+			continue
+		}
+		break
+	}
+	if end == start {
+		return lines, false
+	}
+
+	var block strings.Builder
+	for _, l := range lines[start:end] {
+		block.WriteString(l.Text + "\n")
+	}
+	if !licenseText.MatchString(block.String()) {
+		return lines, false
+	}
+
+	// Also remove the blank line after the header
+	if end < len(lines) && strings.TrimSpace(lines[end].Text) == "" {
+		end++
+	}
+	result := append([]srcLine{}, lines[:start]...)
+	return append(result, lines[end:]...), true
+}
+
+// Remove the bodies of the Go functions, keeping their signatures. It
+// returns false if the file cannot be parsed.
+func goOutline(lines []srcLine) ([]srcLine, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", joinLines(lines), parser.ParseComments)
+	if err != nil {
+		return lines, false
+	}
+
+	// Positions (line, column) of the braces of every body
+	type span struct{ startLine, startCol, endLine, endCol int }
+	var bodies []span
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		lbrace := fset.Position(fn.Body.Lbrace)
+		rbrace := fset.Position(fn.Body.Rbrace)
+		bodies = append(bodies, span{lbrace.Line, lbrace.Column, rbrace.Line, rbrace.Column})
+	}
+	if len(bodies) == 0 {
+		return lines, true
+	}
+
+	var out []srcLine
+	next := 0
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		if next >= len(bodies) || lineNum < bodies[next].startLine {
+			out = append(out, lines[i])
+			continue
+		}
+
+		// Keep the text around the bodies, several of them may start on
+		// the line where the previous one ends
+		text := ""
+		line, col := i, 0
+		for next < len(bodies) && bodies[next].startLine == line+1 {
+			body := bodies[next]
+			cur := lines[line].Text
+			if body.startCol-1 < col || body.startCol-1 > len(cur) ||
+				body.endLine > len(lines) || body.endCol > len(lines[body.endLine-1].Text) {
+				return lines, false
+			}
+			text = appendOutline(text, cur[col:body.startCol-1])
+			line, col = body.endLine-1, body.endCol
+			next++
+		}
+		text = appendOutline(text, lines[line].Text[col:])
+		out = append(out, srcLine{Num: lines[i].Num, Text: text})
+		i = line
+	}
+	return out, true
+}
+
+// Add the text found between two bodies to an outlined line
+func appendOutline(text string, part string) string {
+	if text == "" {
+		return strings.TrimRight(part, " \t")
+	}
+	part = strings.TrimSpace(part)
+	if part == "" || strings.HasPrefix(part, ";") {
+		return text + part
+	}
+	return text + " " + part
+}
@@ -0,0 +1,33 @@
+package export
+
+import "testing"
+
+func TestGoOutline(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"bodies", "package p\n\nfunc a() {\n\treturn\n}\n\nfunc b() int { return 1 }\n", "package p\n\nfunc a()\n\nfunc b() int\n"},
+		{"same line", "package p\n\nfunc a() {}; func b() { return }\nvar x = 1\n", "package p\n\nfunc a(); func b()\nvar x = 1\n"},
+		{"after a multiline body", "package p\n\nfunc a() {\n}; func b() {\n\treturn\n}\n", "package p\n\nfunc a(); func b()\n"},
+		{"comment after", "package p\n\nfunc a() {} // done\n", "package p\n\nfunc a() // done\n"},
+	}
+	for _, tt := range tests {
+		outlined, ok := goOutline(splitLines(tt.src))
+		if !ok {
+			t.Errorf("%s: goOutline(%q) failed", tt.name, tt.src)
+			continue
+		}
+		if got := joinLines(outlined); got != tt.want {
+			t.Errorf("%s: goOutline(%q) = %q, want %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestGoOutlineOutOfRange(t *testing.T) {
+	// A line break inside a line shifts the positions of the parser
+	lines := []srcLine{{1, "package p\nfunc a() {"}, {2, "}"}}
+	outlined, ok := goOutline(lines)
+	if ok || joinLines(outlined) != joinLines(lines) {
+		t.Errorf("goOutline(%q) = %q, %v, want the lines unchanged", joinLines(lines), joinLines(outlined), ok)
+	}
+}
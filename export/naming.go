@@ -0,0 +1,251 @@
+package export
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Naming strategies for existing output files
+const (
+	NamingOverwrite = "overwrite" // Replace the existing file
+	NamingVersioned = "versioned" // Add a -1, -2... suffix to keep the previous files
+)
+
+// DefaultTemplate is the file name template used when none is configured
+const DefaultTemplate = "{project}-{date}-{hash}.{ext}"
+
+// OutputOptions describes where the exported files are written
+type OutputOptions struct {
+	Dir         string `json:"dir"`          // Output directory, empty for the cache directory
+	Template    string `json:"template"`     // File name template
+	Naming      string `json:"naming"`       // Overwrite or versioned names
+	CleanupDays int    `json:"cleanup_days"` // Remove outputs older than this, 0 to keep them
+}
+
+// DefaultOutputDir returns the directory used when no output directory is configured
+func DefaultOutputDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "catsel", "bundles")
+}
+
+// OutputDir resolves the output directory, relative paths are relative to the root directory
+func OutputDir(opts OutputOptions, rootDir string) string {
+	dir := opts.Dir
+	if dir == "" {
+		return DefaultOutputDir()
+	}
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(rootDir, dir)
+	}
+	return dir
+}
+
+// OutputPath builds the path of the output file for a list of files, creating
+// the output directory if necessary. The name only depends on the template, so
+// exporting the same selection twice on the same day gives the same name.
+func OutputPath(files []string, rootDir string, ext string, opts Options) (string, error) {
+	dir := OutputDir(opts.Output, rootDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Remove the old outputs once per session, before writing the first one
+	cleanupOnce.Do(func() { CleanupOutputs(opts.Output, rootDir) })
+
+	name := expandTemplate(opts.Output.Template, files, rootDir, ext, opts.SetName, time.Now())
+	path := filepath.Join(dir, name)
+
+	if opts.Output.Naming == NamingVersioned {
+		base, extension := splitExt(path)
+		for i := 1; ; i++ {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+			path = fmt.Sprintf("%s-%d%s", base, i, extension)
+		}
+	}
+	return path, nil
+}
+
+// Split a path in base and extension, keeping ".tar.gz" as one extension
+func splitExt(path string) (string, string) {
+	if strings.HasSuffix(path, ".tar.gz") {
+		return strings.TrimSuffix(path, ".tar.gz"), ".tar.gz"
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// Replace the placeholders of a file name template:
+// {project}, {date}, {time}, {set}, {hash} and {ext}
+func expandTemplate(template string, files []string, rootDir string, ext string, setName string, now time.Time) string {
+	if template == "" {
+		template = DefaultTemplate
+	}
+	if setName == "" {
+		setName = "selection"
+	}
+
+	// Hash of the sorted paths, so it identifies the selection
+	filesCopy := make([]string, len(files))
+	copy(filesCopy, files)
+	sort.Strings(filesCopy)
+	hasher := md5.New()
+	hasher.Write([]byte(strings.Join(filesCopy, "\n")))
+	hashValue := hex.EncodeToString(hasher.Sum(nil))[:8]
+
+	replacer := strings.NewReplacer(
+		"{project}", sanitizeName(filepath.Base(rootDir)),
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{set}", sanitizeName(setName),
+		"{hash}", hashValue,
+		"{ext}", ext,
+	)
+	return sanitizeName(replacer.Replace(template))
+}
+
+// Replace the characters that are not valid in a file name
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// Old outputs are removed at the first export of a session
+var cleanupOnce sync.Once
+
+// CleanupOutputs removes the outputs older than the configured number of days.
+// Only the files matching the name template in the default output directory
+// are removed: a configured directory may hold files catsel did not write.
+// It returns the number of removed files.
+func CleanupOutputs(opts OutputOptions, rootDir string) int {
+	if opts.CleanupDays <= 0 {
+		return 0
+	}
+	dir := OutputDir(opts, rootDir)
+	if filepath.Clean(dir) != filepath.Clean(DefaultOutputDir()) {
+		return 0
+	}
+
+	template := opts.Template
+	if template == "" {
+		template = DefaultTemplate
+	}
+	// Every placeholder matches any text
+	pattern := template
+	for _, placeholder := range []string{"{project}", "{date}", "{time}", "{set}", "{hash}", "{ext}"} {
+		pattern = strings.ReplaceAll(pattern, placeholder, "*")
+	}
+	pattern = sanitizeName(strings.ReplaceAll(pattern, "*", "\x00"))
+	pattern = strings.ReplaceAll(pattern, "\x00", "*")
+
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return 0
+	}
+
+	limit := time.Now().AddDate(0, 0, -opts.CleanupDays)
+	removed := 0
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() || !info.ModTime().Before(limit) {
+			continue
+		}
+		if os.Remove(match) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// MoveOutput moves an output file to a fixed path, replacing it atomically
+// so that readers never see a partial file
+func MoveOutput(src string, dst string) error {
+	if src == dst {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if os.Rename(src, dst) == nil {
+		return nil
+	}
+
+	// Different file systems: copy next to the destination, then rename
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Remove(src)
+	return nil
+}
+
+// RegenerateOptions returns output options writing a new version of output
+// under a hidden name in the same directory, so that MoveOutput replaces
+// it with a rename
+func RegenerateOptions(output string) OutputOptions {
+	return OutputOptions{
+		Dir:      filepath.Dir(output),
+		Template: "." + filepath.Base(output) + ".new",
+		Naming:   NamingOverwrite,
+	}
+}
+
+// ReplaceOutput moves the bundle of an export, and its sidecar manifest, to output
+func ReplaceOutput(result Result, output string) error {
+	if err := MoveOutput(result.Parts[0], output); err != nil {
+		return err
+	}
+	if result.Manifest != "" {
+		return MoveOutput(result.Manifest, SidecarPath(output))
+	}
+	return nil
+}
+
+// IsOutputFile checks if a path is output itself, its sidecar manifest or one
+// of the hidden files written to replace them (see RegenerateOptions and MoveOutput)
+func IsOutputFile(path string, output string) bool {
+	if path == output || path == SidecarPath(output) {
+		return true
+	}
+	return filepath.Dir(path) == filepath.Dir(output) &&
+		strings.HasPrefix(filepath.Base(path), "."+filepath.Base(output)+".")
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Options controls how the selected files are collected and exported
+type Options struct {
+	ShowHidden     bool              // Include hidden entries when expanding directories
+	HiddenPatterns []string          // Extra name patterns treated as hidden
+	Order          OrderOptions      // Order and grouping of the exported files
+	Sort           SortOptions       // Sort mode of the panels, used by the "sort" order
+	SelectionOrder map[string]int64  // Time when each path was selected, used by the "selection" order
+	Output         OutputOptions     // Location and name of the output files
+	SetName        string            // Name of the selection set, used in the output name
+	Chunk          ChunkOptions      // Maximum size of every part of the bundle
+	NonUTF8        string            // Policy for text files that are not UTF-8
+	Redact         RedactOptions     // Redaction of secrets
+	Limit          LimitOptions      // Per-file size limits
+	Minify         MinifyOptions     // Transforms making the content smaller
+	LineNumbers    LineNumberOptions // Original line numbers in the exported content
+	Manifest       string            // Manifest mode: none, section, sidecar or both
+	Version        string            // Version of the tool, written in the manifest
+	Template       string            // Text of the prompt template wrapping the bundle, empty for none
+	Prompt         string            // Text entered by the user for the prompt template
+}
+
+// Policies for text files that are not UTF-8 (binary files are always skipped)
+const (
+	EncodingTranscode = "transcode" // Convert the content to UTF-8
+	EncodingSkip      = "skip"      // Leave the file out of the bundle
+)
+
+// IsHidden checks if an entry name is hidden: dotfiles and names
+// matching one of the given patterns
+func IsHidden(name string, patterns []string) bool {
+	if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if an entry must be skipped when expanding a directory
+func (o Options) skip(name string) bool {
+	return !o.ShowHidden && IsHidden(name, o.HiddenPatterns)
+}
+
+// Export formats
+const (
+	FormatText  = "text"   // Concatenated text bundle
+	FormatHTML  = "html"   // Self-contained HTML report
+	FormatTarGz = "tar.gz" // Gzipped tar archive of the files
+	FormatZip   = "zip"    // Zip archive of the files
+)
+
+// Formats lists the export formats
+var Formats = []string{FormatText, FormatHTML, FormatTarGz, FormatZip}
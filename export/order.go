@@ -0,0 +1,243 @@
+package export
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export order modes
+const (
+	OrderPath       = "path"       // Natural path order (default)
+	OrderSelection  = "selection"  // Order in which the items were selected
+	OrderSort       = "sort"       // Sort mode of the panels
+	OrderDependency = "dependency" // Go packages after the packages they import
+)
+
+// OrderOptions describes the order and grouping of the exported files
+type OrderOptions struct {
+	Mode      string   `json:"mode"`       // One of the order modes
+	DocsFirst bool     `json:"docs_first"` // README files and docs directories first
+	TestsLast bool     `json:"tests_last"` // Test files last
+	Priority  []string `json:"priority"`   // Patterns exported first, in this order
+}
+
+// OrderFiles sorts the collected files according to the export options.
+// Paths are grouped first (priority patterns, docs, others, tests) and
+// then ordered inside each group by the order mode.
+func OrderFiles(files []string, baseDir string, opts Options) {
+	// Start from the path order so every mode is deterministic
+	SortPaths(files, SortOptions{Mode: SortName})
+
+	switch opts.Order.Mode {
+	case OrderSelection:
+		sort.SliceStable(files, func(i, j int) bool {
+			return selectionTime(files[i], opts.SelectionOrder) < selectionTime(files[j], opts.SelectionOrder)
+		})
+	case OrderSort:
+		SortPaths(files, opts.Sort)
+	case OrderDependency:
+		ranks := dependencyRanks(files)
+		sort.SliceStable(files, func(i, j int) bool {
+			return ranks[filepath.Dir(files[i])] < ranks[filepath.Dir(files[j])]
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return groupRank(files[i], baseDir, opts.Order) < groupRank(files[j], baseDir, opts.Order)
+	})
+}
+
+// Get the time when a file was selected, directly or through one of its
+// parent directories. Files that were never selected go last.
+func selectionTime(path string, order map[string]int64) int64 {
+	for p := path; ; p = filepath.Dir(p) {
+		if t, ok := order[p]; ok {
+			return t
+		}
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	return int64(^uint64(0) >> 1)
+}
+
+// Get the group of a file: priority patterns first, then docs,
+// then the rest of the files and finally the tests
+func groupRank(path string, baseDir string, order OrderOptions) int {
+	relPath, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		relPath = path
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for i, pattern := range order.Priority {
+		if matchPattern(pattern, relPath) {
+			return i
+		}
+	}
+	rank := len(order.Priority)
+	switch {
+	case order.DocsFirst && isDocFile(relPath):
+		return rank
+	case order.TestsLast && isTestFile(relPath):
+		return rank + 2
+	}
+	return rank + 1
+}
+
+// Check if a pattern matches a relative path or its base name
+func matchPattern(pattern string, relPath string) bool {
+	if matched, _ := filepath.Match(pattern, relPath); matched {
+		return true
+	}
+	matched, _ := filepath.Match(pattern, filepath.Base(relPath))
+	return matched
+}
+
+// Check if a file is a README or lives in a docs directory
+func isDocFile(relPath string) bool {
+	base := strings.ToLower(filepath.Base(relPath))
+	if strings.HasPrefix(base, "readme") {
+		return true
+	}
+	parts := strings.Split(relPath, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if part == "doc" || part == "docs" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if a file is a test according to the common naming conventions
+func isTestFile(relPath string) bool {
+	base := strings.ToLower(filepath.Base(relPath))
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if strings.HasSuffix(name, "_test") || strings.HasPrefix(name, "test_") ||
+		strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec") {
+		return true
+	}
+	parts := strings.Split(relPath, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if part == "test" || part == "tests" || part == "__tests__" {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank the directories of the Go packages so that every package goes after
+// the packages it imports. Directories without Go files, and packages in an
+// import cycle, go last in path order.
+func dependencyRanks(files []string) map[string]int {
+	// Imports of every package directory in the export
+	imports := make(map[string][]string)
+	var dirs []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if _, ok := imports[dir]; !ok {
+			imports[dir] = []string{}
+			dirs = append(dirs, dir)
+		}
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range parsed.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[dir] = append(imports[dir], path)
+			}
+		}
+	}
+
+	// Resolve the imports of the module to package directories
+	deps := make(map[string]map[string]bool)
+	for _, dir := range dirs {
+		deps[dir] = make(map[string]bool)
+		moduleRoot, modulePath := findModule(dir)
+		if moduleRoot == "" {
+			continue
+		}
+		for _, path := range imports[dir] {
+			if path != modulePath && !strings.HasPrefix(path, modulePath+"/") {
+				continue
+			}
+			depDir := filepath.Join(moduleRoot, filepath.FromSlash(strings.TrimPrefix(path, modulePath)))
+			if _, ok := imports[depDir]; ok && depDir != dir {
+				deps[dir][depDir] = true
+			}
+		}
+	}
+
+	// Topological order, picking the first ready package in path order
+	ranks := make(map[string]int)
+	for len(ranks) < len(dirs) {
+		progress := false
+		for _, dir := range dirs {
+			if _, done := ranks[dir]; done {
+				continue
+			}
+			ready := true
+			for dep := range deps[dir] {
+				if _, done := ranks[dep]; !done {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ranks[dir] = len(ranks)
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+
+	// Packages in a cycle keep their path order after the others
+	for _, dir := range dirs {
+		if _, done := ranks[dir]; !done {
+			ranks[dir] = len(ranks)
+		}
+	}
+
+	// Directories without Go packages go last
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if _, ok := ranks[dir]; !ok {
+			ranks[dir] = len(dirs)
+		}
+	}
+	return ranks
+}
+
+// Find the root directory and the module path of the go.mod above a directory
+func findModule(dir string) (string, string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		content, err := os.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && fields[0] == "module" {
+					return d, strings.Trim(fields[1], `"`)
+				}
+			}
+			return "", ""
+		}
+		if filepath.Dir(d) == d {
+			return "", ""
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOrderFilesGroups(t *testing.T) {
+	base := "/p"
+	files := []string{
+		"/p/main_test.go",
+		"/p/docs/guide.md",
+		"/p/main.go",
+		"/p/tests/e2e.py",
+		"/p/README.md",
+		"/p/go.mod",
+		"/p/src/app.spec.ts",
+	}
+	tests := []struct {
+		name  string
+		order OrderOptions
+		want  []string
+	}{
+		{"path", OrderOptions{}, []string{
+			"/p/docs/guide.md", "/p/go.mod", "/p/main.go", "/p/main_test.go",
+			"/p/README.md", "/p/src/app.spec.ts", "/p/tests/e2e.py",
+		}},
+		{"docs first, tests last", OrderOptions{DocsFirst: true, TestsLast: true}, []string{
+			"/p/docs/guide.md", "/p/README.md",
+			"/p/go.mod", "/p/main.go",
+			"/p/main_test.go", "/p/src/app.spec.ts", "/p/tests/e2e.py",
+		}},
+		{"priority", OrderOptions{Priority: []string{"go.mod", "*.go"}, TestsLast: true}, []string{
+			"/p/go.mod", "/p/main.go", "/p/main_test.go",
+			"/p/docs/guide.md", "/p/README.md",
+			"/p/src/app.spec.ts", "/p/tests/e2e.py",
+		}},
+	}
+	for _, tt := range tests {
+		got := slices.Clone(files)
+		OrderFiles(got, base, Options{Order: tt.order})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: OrderFiles = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrderFilesSelection(t *testing.T) {
+	files := []string{"/p/a.go", "/p/b/x.go", "/p/c.go", "/p/d.go"}
+	opts := Options{
+		Order:          OrderOptions{Mode: OrderSelection},
+		SelectionOrder: map[string]int64{"/p/c.go": 1, "/p/b": 2, "/p/a.go": 3},
+	}
+	OrderFiles(files, "/p", opts)
+	want := []string{"/p/c.go", "/p/b/x.go", "/p/a.go", "/p/d.go"}
+	if !slices.Equal(files, want) {
+		t.Errorf("OrderFiles = %q, want %q", files, want)
+	}
+}
+
+func TestOrderFilesDependency(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("go.mod", "module example.com/m\n")
+	files := []string{
+		write("a/a.go", "package a\n\nimport \"example.com/m/b\"\n\nvar _ = b.B\n"),
+		write("b/b.go", "package b\n\nimport \"example.com/m/c\"\n\nvar B = c.C\n"),
+		write("c/c.go", "package c\n\nvar C = 1\n"),
+		write("docs/notes.txt", "notes\n"),
+		write("main.go", "package main\n\nimport \"example.com/m/a\"\n"),
+	}
+	OrderFiles(files, dir, Options{Order: OrderOptions{Mode: OrderDependency}})
+
+	var got []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"c/c.go", "b/b.go", "a/a.go", "main.go", "docs/notes.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("OrderFiles = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Directory of the project templates, looked up from the starting directory
+// to the root of the file system
+const templatesDir = ".catsel/templates"
+
+// PromptData is the data available to the prompt templates
+type PromptData struct {
+	Prompt  string       // Text entered by the user
+	Root    string       // Directory the paths are relative to
+	Date    string       // Date of the export
+	Files   []PromptFile // Exported files
+	Tree    string       // Tree of the exported files
+	Bundle  string       // Files concatenated as in the text bundle
+	Skipped []Skipped    // Skipped files with the reason
+	Git     GitInfo      // Repository of the root directory
+}
+
+// PromptFile is an exported file in a prompt template
+type PromptFile struct {
+	Path    string // Relative path
+	Content string // Content as exported (redacted, minified, limited)
+	Lines   int    // Number of exported lines
+	Size    int64  // Size on disk
+	Notes   string // Notes of the file header
+}
+
+// GitInfo describes the git repository of the exported files, empty
+// outside of a repository
+type GitInfo struct {
+	Branch string // Current branch
+	Commit string // Short hash of HEAD
+	Remote string // URL of the "origin" remote
+	Status string // Short status of the working tree
+	Dirty  bool   // The working tree has changes
+}
+
+// Functions available in the prompt templates
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"lang": language,
+}
+
+// FindTemplates returns the prompt templates by name: the configured ones
+// and the files of the nearest .catsel/templates directory, which take
+// precedence. The name of a file template is its name without extension.
+func FindTemplates(configured map[string]string, dir string) map[string]string {
+	templates := make(map[string]string)
+	for name, text := range configured {
+		templates[name] = text
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		entries, err := os.ReadDir(filepath.Join(d, templatesDir))
+		if err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				data, err := os.ReadFile(filepath.Join(d, templatesDir, entry.Name()))
+				if err != nil {
+					continue
+				}
+				name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+				templates[name] = string(data)
+			}
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return templates
+}
+
+// TemplateNames returns the sorted names of a set of templates
+func TemplateNames(templates map[string]string) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render the bundle through the prompt template and write it as a single
+// file. Templated bundles are never split in parts.
+func writePromptBundle(sections []section, skipped []Skipped, manifest *Manifest, files []string, rootDir string, opts Options) Result {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Parse(opts.Template)
+	if err != nil {
+		return Result{Error: err}
+	}
+
+	data := PromptData{
+		Prompt:  opts.Prompt,
+		Root:    rootDir,
+		Date:    time.Now().Format("2006-01-02"),
+		Skipped: skipped,
+		Git:     gitInfo(rootDir),
+	}
+	var bundle strings.Builder
+	if manifest.inSection(opts) {
+		bundle.WriteString(manifest.render())
+	}
+	var names []string
+	for _, sec := range sections {
+		data.Files = append(data.Files, PromptFile{
+			Path:    sec.Name,
+			Content: sec.Content,
+			Lines:   len(sec.Lines),
+			Size:    sec.Size,
+			Notes:   strings.Join(sec.Notes, "; "),
+		})
+		names = append(names, sec.Name)
+		bundle.WriteString(renderSection(sec, sec.Content, ""))
+	}
+	if len(skipped) > 0 {
+		bundle.WriteString(skippedFooter(skipped))
+	}
+	data.Bundle = bundle.String()
+	data.Tree = textTree(names)
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return Result{Error: err}
+	}
+
+	outputFile, err := OutputPath(files, rootDir, "txt", opts)
+	if err != nil {
+		return Result{Error: err}
+	}
+	if err := os.WriteFile(outputFile, out.Bytes(), 0644); err != nil {
+		return Result{Error: err}
+	}
+	result := Result{Parts: []string{outputFile}, Files: len(sections), Skipped: skipped, Redacted: countRedacted(sections)}
+	if manifest.inSidecar(opts) {
+		result.Manifest, _ = manifest.writeSidecar(outputFile)
+	}
+	return result
+}
+
+// Render a list of relative paths as a tree:
+//
+//	src/
+//	├── main.go
+//	└── util.go
+func textTree(names []string) string {
+	type node struct {
+		dirs  map[string]*node
+		files []string
+	}
+	root := &node{dirs: make(map[string]*node)}
+	for _, name := range names {
+		n := root
+		parts := strings.Split(name, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			if n.dirs[dir] == nil {
+				n.dirs[dir] = &node{dirs: make(map[string]*node)}
+			}
+			n = n.dirs[dir]
+		}
+		n.files = append(n.files, parts[len(parts)-1])
+	}
+
+	var b strings.Builder
+	var render func(n *node, indent string)
+	render = func(n *node, indent string) {
+		// Directories first, then files, by natural order
+		var dirs []string
+		for dir := range n.dirs {
+			dirs = append(dirs, dir)
+		}
+		sort.Slice(dirs, func(i, j int) bool { return NaturalCompare(dirs[i], dirs[j]) < 0 })
+		sort.Slice(n.files, func(i, j int) bool { return NaturalCompare(n.files[i], n.files[j]) < 0 })
+
+		total := len(dirs) + len(n.files)
+		for i, entry := range append(dirs, n.files...) {
+			branch, next := "├── ", "│   "
+			if i == total-1 {
+				branch, next = "└── ", "    "
+			}
+			if i < len(dirs) {
+				b.WriteString(indent + branch + entry + "/\n")
+				render(n.dirs[entry], indent+next)
+			} else {
+				b.WriteString(indent + branch + entry + "\n")
+			}
+		}
+	}
+	render(root, "")
+	return b.String()
+}
+
+// Get the information of the git repository of a directory
+func gitInfo(dir string) GitInfo {
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimRight(string(out), "\n")
+	}
+
+	info := GitInfo{Commit: git("rev-parse", "--short", "HEAD")}
+	if info.Commit == "" {
+		return GitInfo{}
+	}
+	info.Branch = git("rev-parse", "--abbrev-ref", "HEAD")
+	info.Remote = git("remote", "get-url", "origin")
+	info.Status = git("status", "--short")
+	info.Dirty = info.Status != ""
+	return info
+}
+
+// Name of the language of a file for fenced code blocks (e.g. "go")
+func language(name string) string {
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case "":
+		if hashFiles[filepath.Base(name)] {
+			return strings.ToLower(filepath.Base(name))
+		}
+		return ""
+	case ".js", ".mjs", ".cjs":
+		return "javascript"
+	case ".ts":
+		return "typescript"
+	case ".py":
+		return "python"
+	case ".rb":
+		return "ruby"
+	case ".rs":
+		return "rust"
+	case ".sh", ".bash", ".zsh":
+		return "bash"
+	case ".yml":
+		return "yaml"
+	case ".md":
+		return "markdown"
+	case ".h":
+		return "c"
+	case ".hpp", ".cc":
+		return "cpp"
+	default:
+		return strings.TrimPrefix(ext, ".")
+	}
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RedactOptions controls the redaction of secrets before exporting
+type RedactOptions struct {
+	Enabled     bool     `json:"enabled"`      // Redact the detected secrets
+	HighEntropy bool     `json:"high_entropy"` // Also redact long random-looking tokens
+	Patterns    []string `json:"patterns"`     // Custom regular expressions to redact
+	Deny        []string `json:"deny"`         // Files never exported (e.g. "*.pem")
+}
+
+// Detector of a kind of secret
+type detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Built-in detectors of common secrets
+var detectors = []detector{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+}
+
+// Assignments in env files: the value is redacted
+var envAssignment = regexp.MustCompile(`(?m)^(\s*(?:export\s+)?[A-Za-z_][A-Za-z0-9_.]*\s*=\s*)(\S.*)$`)
+
+// Candidates for the high entropy detection
+var tokenCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// Files with checksums that the high entropy detection would destroy
+var lockFiles = []string{"go.sum", "*.lock", "package-lock.json", "pnpm-lock.yaml"}
+
+// Placeholder written instead of a secret
+func placeholder(name string) string {
+	return fmt.Sprintf("[REDACTED:%s]", name)
+}
+
+// Redactor replaces the secrets of the exported files
+type redactor struct {
+	opts   RedactOptions
+	custom []*regexp.Regexp
+}
+
+// Build a redactor. An invalid custom pattern is an error: exporting
+// without it would leave the secrets it is meant to hide.
+func newRedactor(opts RedactOptions) (*redactor, error) {
+	r := &redactor{opts: opts}
+	for _, pattern := range opts.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.custom = append(r.custom, re)
+	}
+	return r, nil
+}
+
+// Check if a file is in the deny list
+func (r *redactor) denied(relPath string) bool {
+	for _, pattern := range r.opts.Deny {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact the secrets of the content of a file and return the number of
+// redacted secrets
+func (r *redactor) redact(relPath string, text string) (string, int) {
+	if !r.opts.Enabled {
+		return text, 0
+	}
+	count := 0
+	replace := func(re *regexp.Regexp, name string) {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			count++
+			// Keep the line breaks so the following lines keep their numbers
+			return placeholder(name) + strings.Repeat("\n", strings.Count(match, "\n"))
+		})
+	}
+
+	for _, d := range detectors {
+		replace(d.Pattern, d.Name)
+	}
+	for _, re := range r.custom {
+		replace(re, "custom")
+	}
+
+	if isEnvFile(relPath) {
+		text = envAssignment.ReplaceAllStringFunc(text, func(line string) string {
+			groups := envAssignment.FindStringSubmatch(line)
+			value := strings.TrimSpace(groups[2])
+			if strings.HasPrefix(value, "#") || strings.HasPrefix(value, "[REDACTED") {
+				return line
+			}
+			count++
+			return groups[1] + placeholder("env")
+		})
+	}
+
+	if r.opts.HighEntropy && !isLockFile(relPath) {
+		text = tokenCandidate.ReplaceAllStringFunc(text, func(token string) string {
+			if !looksRandom(token) {
+				return token
+			}
+			count++
+			return placeholder("token")
+		})
+	}
+	return text, count
+}
+
+// Check if a file contains environment variables (.env, .env.local, prod.env...)
+func isEnvFile(relPath string) bool {
+	base := filepath.Base(relPath)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// Check if a file is a lock file with checksums
+func isLockFile(relPath string) bool {
+	for _, pattern := range lockFiles {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if a token looks like a random secret: it mixes upper and lower
+// case letters with several digits and has a high Shannon entropy
+func looksRandom(token string) bool {
+	upper, lower, digits := 0, 0, 0
+	for _, c := range token {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper++
+		case c >= 'a' && c <= 'z':
+			lower++
+		case c >= '0' && c <= '9':
+			digits++
+		}
+	}
+	// Identifiers have few digits, hashes in hex have no upper case letters
+	if digits < 3 || upper < 2 || lower < 2 {
+		return false
+	}
+	return entropy(token) > 4.0
+}
+
+// Shannon entropy of a string in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var result float64
+	total := float64(len(s))
+	for _, n := range counts {
+		p := float64(n) / total
+		result -= p * math.Log2(p)
+	}
+	return result
+}
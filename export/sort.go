@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sort modes
+const (
+	SortName      = "name"      // Natural (version-aware) name order
+	SortExtension = "extension" // Extension, then name
+	SortSize      = "size"      // File size
+	SortModTime   = "mtime"     // Modification time
+	SortLines     = "lines"     // Number of lines
+)
+
+// SortModes lists the sort modes in the order they are cycled
+var SortModes = []string{SortName, SortExtension, SortSize, SortModTime, SortLines}
+
+// SortOptions describes how files and directories are ordered
+type SortOptions struct {
+	Mode       string `json:"mode"`       // One of SortModes
+	Descending bool   `json:"descending"` // Reverse the order
+	DirsFirst  bool   `json:"dirs_first"` // Subdirectory contents before the files of a directory (exports)
+}
+
+// NextSortMode returns the sort mode following the given one
+func NextSortMode(mode string) string {
+	for i, m := range SortModes {
+		if m == mode {
+			return SortModes[(i+1)%len(SortModes)]
+		}
+	}
+	return SortName
+}
+
+// SortNames sorts the names of the entries of a directory
+func SortNames(dir string, names []string, opts SortOptions) {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+	}
+	sortByPath(names, paths, opts)
+}
+
+// SortPaths sorts full paths, grouping them by directory when sorting by name
+func SortPaths(paths []string, opts SortOptions) {
+	sortByPath(paths, paths, opts)
+}
+
+// Item being sorted with the key of its path, computed once per sort
+type sortEntry struct {
+	item string
+	path string
+	key  int64
+}
+
+// Sort the items by their paths according to the sort options
+func sortByPath(items []string, paths []string, opts SortOptions) {
+	entries := make([]sortEntry, len(items))
+	for i := range items {
+		entries[i] = sortEntry{item: items[i], path: paths[i]}
+		if opts.Mode != SortName && opts.Mode != "" {
+			entries[i].key = sortKey(paths[i], opts.Mode)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		c := compareEntries(entries[i], entries[j], opts)
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+	for i, e := range entries {
+		items[i] = e.item
+	}
+}
+
+// Compare two entries in ascending order: by key, by extension in the
+// extension mode, then by path
+func compareEntries(a, b sortEntry, opts SortOptions) int {
+	if a.key != b.key {
+		if a.key < b.key {
+			return -1
+		}
+		return 1
+	}
+	if opts.Mode == SortExtension {
+		ea, eb := strings.ToLower(filepath.Ext(a.path)), strings.ToLower(filepath.Ext(b.path))
+		if c := strings.Compare(ea, eb); c != 0 {
+			return c
+		}
+	}
+	return comparePaths(a.path, b.path, opts.DirsFirst)
+}
+
+// Compare two paths component by component using natural order.
+// With dirsFirst, entries inside a subdirectory go before the files
+// of the directory that contains it.
+func comparePaths(a, b string, dirsFirst bool) int {
+	pa := strings.Split(filepath.ToSlash(a), "/")
+	pb := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] == pb[i] {
+			continue
+		}
+		if dirsFirst {
+			aIsDir := i < len(pa)-1
+			bIsDir := i < len(pb)-1
+			if aIsDir != bIsDir {
+				if aIsDir {
+					return -1
+				}
+				return 1
+			}
+		}
+		return NaturalCompare(pa[i], pb[i])
+	}
+	return len(pa) - len(pb)
+}
+
+// NaturalCompare compares two strings treating runs of digits as numbers,
+// so "file2" goes before "file10". The comparison ignores case and falls
+// back to the byte order to stay deterministic.
+func NaturalCompare(a, b string) int {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	i, j := 0, 0
+	for i < len(la) && j < len(lb) {
+		ca, cb := la[i], lb[j]
+		if isDigit(ca) && isDigit(cb) {
+			// Compare the whole numbers
+			si := i
+			for i < len(la) && isDigit(la[i]) {
+				i++
+			}
+			sj := j
+			for j < len(lb) && isDigit(lb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(la[si:i], "0")
+			nb := strings.TrimLeft(lb[sj:j], "0")
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if na != nb {
+				return strings.Compare(na, nb)
+			}
+			continue
+		}
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+		i++
+		j++
+	}
+	if len(la)-i != len(lb)-j {
+		return (len(la) - i) - (len(lb) - j)
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Cached line count of a file, valid while the file is not modified
+type lineCount struct {
+	modTime time.Time
+	size    int64
+	lines   int64
+}
+
+// Line counts are cached because listings are sorted on every render.
+// The cache is shared by the interface and the watch exports.
+var (
+	lineCache   = make(map[string]lineCount)
+	lineCacheMu sync.Mutex
+)
+
+// Get the numeric key used to sort a path. Directories only have a
+// modification time; the other keys are zero and fall back to the name.
+func sortKey(path string, mode string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	switch mode {
+	case SortModTime:
+		return info.ModTime().UnixNano()
+	case SortSize:
+		if info.IsDir() {
+			return 0
+		}
+		return info.Size()
+	case SortLines:
+		if info.IsDir() {
+			return 0
+		}
+		return countLines(path, info)
+	}
+	return 0
+}
+
+// Count the lines of a file using the cache when possible
+func countLines(path string, info os.FileInfo) int64 {
+	lineCacheMu.Lock()
+	cached, ok := lineCache[path]
+	lineCacheMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.lines
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	lines := int64(bytes.Count(content, []byte("\n")))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	lineCacheMu.Lock()
+	lineCache[path] = lineCount{modTime: info.ModTime(), size: info.Size(), lines: lines}
+	lineCacheMu.Unlock()
+	return lines
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // Sign of the result
+	}{
+		{"file2", "file10", -1},
+		{"file10", "file2", 1},
+		{"file02", "file2", -1}, // Same number, byte order decides
+		{"File1", "file1", -1},
+		{"a", "a", 0},
+		{"a", "ab", -1},
+		{"abc", "abd", -1},
+		{"v1.9", "v1.10", -1},
+		{"x100y", "x99y", 1},
+		{"readme", "README2", -1},
+	}
+	for _, tt := range tests {
+		got := NaturalCompare(tt.a, tt.b)
+		if sign(got) != tt.want {
+			t.Errorf("NaturalCompare(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortPathsByName(t *testing.T) {
+	paths := []string{"/p/file10.go", "/p/sub/a.go", "/p/file2.go", "/p/B.go"}
+	SortPaths(paths, SortOptions{Mode: SortName})
+	want := []string{"/p/B.go", "/p/file2.go", "/p/file10.go", "/p/sub/a.go"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("SortPaths = %q, want %q", paths, want)
+	}
+
+	SortPaths(paths, SortOptions{Mode: SortName, DirsFirst: true})
+	want = []string{"/p/sub/a.go", "/p/B.go", "/p/file2.go", "/p/file10.go"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("SortPaths with dirs first = %q, want %q", paths, want)
+	}
+
+	SortPaths(paths, SortOptions{Mode: SortName, Descending: true})
+	want = []string{"/p/sub/a.go", "/p/file10.go", "/p/file2.go", "/p/B.go"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("SortPaths descending = %q, want %q", paths, want)
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
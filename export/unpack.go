@@ -0,0 +1,195 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// UnpackedFile is a file read back from a bundle
+type UnpackedFile struct {
+	Name    string   // Relative path
+	Content string   // Content of the file
+	Notes   []string // Notes of the header (e.g. "truncated: ...")
+}
+
+// Lossy reports whether the content is not the whole original file
+// (truncated, summarized, minified or with redacted secrets)
+func (f UnpackedFile) Lossy() bool {
+	for _, note := range f.Notes {
+		for _, prefix := range []string{"truncated", "summary", "minified", "outline"} {
+			if strings.HasPrefix(note, prefix) {
+				return true
+			}
+		}
+		if strings.HasSuffix(note, "secrets redacted") {
+			return true
+		}
+	}
+	return false
+}
+
+// Header of a file in the text format: name, optional part label and notes
+var fileHeader = regexp.MustCompile(`^// File (.+?)( \(part \d+/\d+(?:, line continues)?\))?( \[[^\]]*\])?$`)
+
+// ParseBundle reads the files of a bundle in the text format. The fragments
+// of files split across parts are joined in order, and the line numbers are
+// removed if every line of a file is prefixed with the given separator.
+// A fragment cut inside a line ends with a line break that is dropped, and
+// so does a file noted without a final newline.
+func ParseBundle(text string, separator string) ([]UnpackedFile, error) {
+	if format := unsupportedFormat(text); format != "" {
+		return nil, fmt.Errorf("%s cannot be unpacked, only text bundles can", format)
+	}
+	lines := strings.SplitAfter(text, "\n")
+	var files []UnpackedFile
+	index := make(map[string]int)
+
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") != strings.TrimSuffix(fileSeparator, "\n") || i+1 >= len(lines) {
+			continue
+		}
+		match := fileHeader.FindStringSubmatch(strings.TrimRight(lines[i+1], "\r\n"))
+		if match == nil {
+			continue
+		}
+		name, label := match[1], match[2]
+		var notes []string
+		if match[3] != "" {
+			notes = strings.Split(strings.Trim(match[3], " []"), "; ")
+		}
+
+		// Content until the end marker of the same file
+		end := "// End of file " + name + label
+		var b strings.Builder
+		j := i + 2
+		for ; j < len(lines); j++ {
+			if strings.TrimRight(lines[j], "\r\n") == end {
+				break
+			}
+			b.WriteString(lines[j])
+		}
+		if j >= len(lines) {
+			return nil, fmt.Errorf("missing end of file %s", name)
+		}
+		i = j
+
+		fileContent := b.String()
+		if strings.HasSuffix(label, lineContinues+")") {
+			fileContent = strings.TrimSuffix(fileContent, "\n")
+		}
+		if k, ok := index[name]; ok && label != "" {
+			files[k].Content += fileContent
+			continue
+		}
+		index[name] = len(files)
+		files = append(files, UnpackedFile{Name: name, Content: fileContent, Notes: notes})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found in the bundle")
+	}
+	for i := range files {
+		files[i].Content = stripLineNumbers(files[i].Content, separator)
+		if slices.Contains(files[i].Notes, noFinalNewline) {
+			files[i].Content = strings.TrimSuffix(files[i].Content, "\n")
+		}
+	}
+	return files, nil
+}
+
+// Recognize the export formats other than text, which are not unpacked:
+// HTML reports and archives (extracted with tar or unzip)
+func unsupportedFormat(text string) string {
+	switch {
+	case strings.HasPrefix(text, "\x1f\x8b"):
+		return "a tar.gz archive"
+	case strings.HasPrefix(text, "PK\x03\x04"):
+		return "a zip archive"
+	case strings.HasPrefix(strings.ToLower(strings.TrimSpace(text)), "<!doctype html"):
+		return "an HTML report"
+	}
+	return ""
+}
+
+// Remove the line numbers of a content if all its lines have them
+func stripLineNumbers(text string, separator string) string {
+	if separator == "" || text == "" {
+		return text
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	width := -1
+	numbered := false
+	for _, line := range lines {
+		idx := strings.Index(line, separator)
+		if idx < 0 || (width >= 0 && idx != width) {
+			return text
+		}
+		width = idx
+		prefix := strings.TrimLeft(line[:idx], " ")
+		for _, c := range prefix {
+			if c < '0' || c > '9' {
+				return text
+			}
+		}
+		if prefix != "" {
+			numbered = true
+		}
+	}
+	if !numbered {
+		return text
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line[width+len(separator):])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// SafePath resolves the path of an unpacked file inside the target
+// directory and refuses paths that would be written outside of it,
+// including through symbolic links
+func SafePath(targetDir string, name string) (string, error) {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("absolute path %s", name)
+	}
+	target, err := filepath.Abs(targetDir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(target, filepath.FromSlash(name))
+	rel, err := filepath.Rel(target, path)
+	if err != nil || rel == "." || escapes(rel) {
+		return "", fmt.Errorf("path %s is outside of %s", name, targetDir)
+	}
+
+	// The deepest existing directory must also be inside the target
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return path, nil // The target does not exist yet
+	}
+	existing := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	rel, err = filepath.Rel(realTarget, realExisting)
+	if err != nil || escapes(rel) {
+		return "", fmt.Errorf("path %s is outside of %s through a symbolic link", name, targetDir)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("path %s is a symbolic link", name)
+	}
+	return path, nil
+}
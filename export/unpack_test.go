@@ -0,0 +1,145 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafePath(t *testing.T) {
+	target := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(target, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A directory of the target pointing outside of it
+	if err := os.Symlink(outside, filepath.Join(target, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// A file of the target that is itself a link
+	if err := os.Symlink(filepath.Join(outside, "x"), filepath.Join(target, "src", "file.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"main.go", true},
+		{"src/main.go", true},
+		{"new/dir/main.go", true},
+		{"src/../main.go", true},
+		{"../evil.txt", false},
+		{"src/../../evil.txt", false},
+		{"..", false},
+		{".", false},
+		{"/etc/passwd", false},
+		{"link/evil.txt", false},
+		{"link/sub/evil.txt", false},
+		{"src/file.go", false},
+	}
+	for _, tt := range tests {
+		path, err := SafePath(target, tt.name)
+		if tt.ok && err != nil {
+			t.Errorf("SafePath(%q) refused: %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("SafePath(%q) = %q, want an error", tt.name, path)
+		}
+		if tt.ok && err == nil && !strings.HasPrefix(path, target+string(filepath.Separator)) {
+			t.Errorf("SafePath(%q) = %q, outside of %q", tt.name, path, target)
+		}
+	}
+}
+
+func TestDiffHunkHeaders(t *testing.T) {
+	tests := []struct {
+		old, new string
+		header   string
+	}{
+		{"", "a\nb\n", "@@ -0,0 +1,2 @@"},
+		{"a\nb\n", "", "@@ -1,2 +0,0 @@"},
+		{"a\n", "a\nb\n", "@@ -1,1 +1,2 @@"},
+	}
+	for _, tt := range tests {
+		diff := Diff("f", tt.old, tt.new)
+		if !strings.Contains(diff, tt.header+"\n") {
+			t.Errorf("Diff(%q, %q) = %q, want header %q", tt.old, tt.new, diff, tt.header)
+		}
+	}
+}
+
+func TestParseBundleRefusesOtherFormats(t *testing.T) {
+	for _, text := range []string{"<!DOCTYPE html>\n<html></html>", "\x1f\x8b\x08\x00", "PK\x03\x04"} {
+		if _, err := ParseBundle(text, ""); err == nil {
+			t.Errorf("ParseBundle(%q) accepted a non-text export", text)
+		}
+	}
+}
+
+func TestParseBundleRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":       "one\ntwo\n",
+		"no-eol.txt":  "last line without break",
+		"src/main.go": "package main\n\nfunc main() {}\n",
+		"blank.txt":   "\n\n",
+		"one-eol.txt": "\n",
+	}
+	var paths []string
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, numbers := range []bool{false, true} {
+		opts := Options{Output: OutputOptions{Dir: t.TempDir()}}
+		opts.LineNumbers = LineNumberOptions{Enabled: numbers, Separator: " | "}
+		result := GenerateCombinedFile(paths, dir, opts)
+		if result.Error != nil || len(result.Parts) != 1 {
+			t.Fatalf("export failed: %+v", result)
+		}
+		bundle, err := os.ReadFile(result.Parts[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		unpacked, err := ParseBundle(string(bundle), opts.LineNumbers.Separator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(unpacked) != len(files) {
+			t.Errorf("line numbers %v: %d files unpacked, want %d", numbers, len(unpacked), len(files))
+		}
+		for _, file := range unpacked {
+			if file.Content != files[file.Name] {
+				t.Errorf("line numbers %v: %s = %q, want %q", numbers, file.Name, file.Content, files[file.Name])
+			}
+		}
+	}
+}
+
+func TestStripLineNumbers(t *testing.T) {
+	tests := []struct {
+		name, text, separator, want string
+	}{
+		{"numbered", " 9 | a\n   | ...\n10 | b\n", " | ", "a\n...\nb\n"},
+		{"no separator given", "1 | a\n", "", "1 | a\n"},
+		{"not every line numbered", "1 | a\nplain\n", " | ", "1 | a\nplain\n"},
+		{"prefix is not a number", "x | a\ny | b\n", " | ", "x | a\ny | b\n"},
+		{"separator in the text", "1 | a | b\n2 | c\n", " | ", "a | b\nc\n"},
+		{"misaligned", "1 | a\n10 | b\n", " | ", "1 | a\n10 | b\n"},
+		{"empty", "", " | ", ""},
+	}
+	for _, tt := range tests {
+		if got := stripLineNumbers(tt.text, tt.separator); got != tt.want {
+			t.Errorf("%s: stripLineNumbers(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"catselector/config"
 	"catselector/core"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "unpack":
+			os.Exit(runUnpack(os.Args[2:]))
+		case "watch":
+			os.Exit(runWatch(os.Args[2:]))
+		}
+	}
+
 	// Check if --help or --version was requested
 	if len(os.Args) > 1 {
 		for _, arg := range os.Args[1:] {
@@ -25,17 +44,159 @@ func main() {
 		}
 	}
 
-	runApp()
+	opts, ok := setupDirectories(os.Args[1:])
+	if !ok {
+		os.Exit(2)
+	}
+
+	// Read the paths to preselect before the interface takes the terminal
+	var paths []string
+	if opts.preselect != "" {
+		var err error
+		paths, err = readPreselection(opts.preselect)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(runApp(opts, paths))
+}
+
+// Options of the interface given on the command line
+type appOptions struct {
+	preselect string // Source of the paths to preselect, "-" for stdin
+	print     bool   // Print the picked paths on exit instead of exporting
+	separator string // Separator of the printed paths
+	absolute  bool   // Print absolute paths
+}
+
+// Set the start directory and the root of the navigation from the arguments,
+// and return the other options of the interface
+func setupDirectories(args []string) (appOptions, bool) {
+	flags := flag.NewFlagSet("catsel", flag.ContinueOnError)
+	root := flags.String("root", "", "directory the navigation cannot go above (default: the start directory)")
+	aboveRoot := flags.Bool("above-root", false, "allow navigating above the root directory")
+	preselect := flags.String("preselect", "", "file with the paths to select at start, - for stdin")
+	printPaths := flags.Bool("print", false, "print the selected files on exit, one per line")
+	printPaths0 := flags.Bool("print0", false, "print the selected files on exit, separated by NUL characters")
+	absolute := flags.Bool("absolute", false, "print absolute paths instead of paths relative to the current directory")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catsel [path | -] [--root dir] [--above-root] [--preselect file] [--print | --print0] [--absolute]")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before or after the path
+	var paths []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return appOptions{}, false
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		// "-" reads the paths to preselect from stdin
+		if flags.Arg(0) == "-" {
+			*preselect = "-"
+		} else {
+			paths = append(paths, flags.Arg(0))
+		}
+		args = flags.Args()[1:]
+	}
+	if len(paths) > 1 {
+		flags.Usage()
+		return appOptions{}, false
+	}
+
+	start := core.GetCurrentDirectory()
+	if len(paths) == 1 {
+		dir, err := directoryArg(paths[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return appOptions{}, false
+		}
+		start = dir
+	}
+	rootDir := start
+	if *root != "" {
+		dir, err := directoryArg(*root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return appOptions{}, false
+		}
+		rootDir = dir
+		if len(paths) == 0 {
+			start = dir
+		}
+	}
+	if rel, err := filepath.Rel(rootDir, start); !*aboveRoot && (err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		fmt.Fprintf(os.Stderr, "Error: %s is outside of the root %s (use --above-root)\n", start, rootDir)
+		return appOptions{}, false
+	}
+
+	core.SetRootDirectory(rootDir)
+	core.SetStartDirectory(start)
+	core.AllowAboveRoot(*aboveRoot)
+
+	opts := appOptions{preselect: *preselect, print: *printPaths || *printPaths0, separator: "\n", absolute: *absolute}
+	if *printPaths0 {
+		opts.separator = "\x00"
+	}
+	return opts, true
+}
+
+// Read the paths to preselect from a file, or from stdin for "-"
+func readPreselection(source string) ([]string, error) {
+	if source == "-" {
+		return core.ReadPathList(os.Stdin)
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return core.ReadPathList(file)
+}
+
+// Resolve a directory given on the command line to an absolute path
+func directoryArg(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+	return dir, nil
 }
 
 func printHelp() {
-	fmt.Println(`Cat Selector - Smart Concatenation Selector
+	fmt.Print(`Cat Selector - Smart Concatenation Selector
 A file browser utility that allows you to select multiple files and concatenate them for viewing or editing.
 
 Usage:
-  catsel            Start Cat Selector
+  catsel [path]     Start Cat Selector in a directory (default: the current one)
+  catsel -          Select the paths read from stdin (newline or NUL separated)
+         --preselect file
+                    Select the paths listed in a file (- for stdin)
+         --print, --print0 [--absolute]
+                    Pick files: q or Enter prints the selected files instead
+                    of exporting them (one per line, or NUL separated)
+         --root dir Do not navigate above dir (default: the start directory)
+         --above-root
+                    Allow navigating above the root directory
   catsel --help     Show this help message
   catsel --version  Show version information
+  catsel export <path>... [--format text|html|tar.gz|zip] [--template name] [--prompt text]
+                    Export files and directories and print the output paths
+  catsel watch <path>... [--set name] [--output file] [--format text|html] [--poll]
+                    Regenerate a bundle every time the files change
+  catsel doctor     Show the configuration and the available clipboard providers
+  catsel unpack <bundle>... [--into dir] [--dry-run] [--force]
+                    Write the files of a bundle back into a directory
 
 Controls:
   j / Down          Move down
@@ -44,25 +205,57 @@ Controls:
   Esc / h           Go to previous directory
   s                 Select or deselect
   a                 Select or deselect all
+  v / V             Start or cancel visual range selection
+  I                 Invert selection in the current panel
   i                 Toggle include subdirectories in selection
-  o                 Concatenate and open selection in external editor
+  .                 Show or hide hidden files
+  S                 Cycle sort mode (name, extension, size, mtime, lines)
+  R                 Reverse sort order
+  o                 Concatenate and open selection (system application or $EDITOR)
+  e                 Edit the file under the cursor in $VISUAL or $EDITOR
+  O                 Export selection as an HTML report and open it
   c                 Concatenate and copy selection to clipboard
+  n                 Copy the next part of a split bundle to clipboard
+  t                 Choose a prompt template, enter a prompt and copy the result
+  p                 Send the selection to a configured command and show its output
+  w                 Start or stop regenerating the bundle when the selected files change
+  z                 Archive the selected files (tar.gz or zip)
   Tab               Switch panel
   f                 Go to files panel
   d                 Go to directories panel
   q                 Quit
+
+Configuration:
+  Preferences are read from the config.json file in the catsel directory
+  of the user configuration directory (e.g. ~/.config/catsel/config.json).
 `)
 }
 
 func printVersion() {
-    fmt.Println("Cat Selector version 1.0.4")
+    fmt.Println("Cat Selector version " + config.Version)
 }
 
-func runApp() {
+// Run the interface and return the exit code
+func runApp(opts appOptions, preselected []string) int {
+	// In picker mode stdout is for the paths, the interface is drawn on the terminal
+	var tty *os.File
+	out := os.Stdout
+	if opts.print {
+		var err error
+		tty, err = os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: cannot open the terminal:", err)
+			return 1
+		}
+		defer tty.Close()
+		core.SetTerminal(tty)
+		out = tty
+	}
+
 	// Enter terminal's alternate screen mode
-	fmt.Print("\033[?1049h")
+	fmt.Fprint(out, "\033[?1049h")
 	// Clear the screen
-	fmt.Print("\033[H\033[2J")
+	fmt.Fprint(out, "\033[H\033[2J")
 
 	// Configure a handler to restore the terminal when exiting
 	c := make(chan os.Signal, 1)
@@ -70,40 +263,74 @@ func runApp() {
 	go func() {
 		<-c
 		// Restore the terminal when exiting
-		fmt.Print("\033[?1049l")
+		fmt.Fprint(out, "\033[?1049l")
 		os.Exit(0)
 	}()
 
+	// Create the initial selector
+	selector := core.Selector{
+		Directory:   core.GetStartDirectory(),
+		ActivePanel: 1,
+		Position:    0,
+		Selection:   make(map[string]bool),
+		SelectedAt:  make(map[string]int64),
+		Files:       []string{},
+		IncludeMode: false,
+		ShowHidden:  config.Get().ShowHidden,
+		DirScroll:   0,
+		FileScroll:  0,
+	}
+	// The listing depends on the selector state (hidden files)
+	core.SetCurrentSelector(&selector)
+	selector.Filtered = core.PrepareDirItems(selector.Directory, selector.ShowHidden)
+	if len(preselected) > 0 {
+		core.Preselect(&selector, preselected)
+	}
+
 	// Create the initial model
+	// Refresh the panels when the shown directories change
+	core.UpdateLiveWatch(&selector)
+
 	initialModel := model{
 		position: 0,
-		items:    core.PrepareDirItems(core.GetRootDirectory()),
-		selected: make(map[string]bool),
-		selector: core.Selector{
-			Directory:   core.GetRootDirectory(),
-			ActivePanel: 1,
-			Position:    0,
-			Selection:   make(map[string]bool),
-			Filtered:    core.PrepareDirItems(core.GetRootDirectory()),
-			Files:       []string{},
-			IncludeMode: false,
-			DirScroll:   0,
-			FileScroll:  0,
-		},
-	}
-
-	// Start the program with the model
-	p := tea.NewProgram(initialModel)
+		items:    selector.Filtered,
+		selected: selector.Selection,
+		selector: selector,
+		live:     selector.Live,
+		picker:   opts.print,
+	}
+
+	// Start the program with the model. The keys are read from the
+	// terminal when stdin was used for the paths to preselect.
+	options := []tea.ProgramOption{tea.WithOutput(core.NewProgramOutput())}
+	if tty != nil {
+		options = append(options, tea.WithInput(tty))
+	} else if opts.preselect == "-" {
+		options = append(options, tea.WithInputTTY())
+	}
+	p := tea.NewProgram(initialModel, options...)
 
 	// Run the application
-	err := p.Start()
+	final, err := p.Run()
 	if err != nil {
-		fmt.Println("Error:", err)
-		fmt.Print("\033[?1049l")
-		os.Exit(1)
+		fmt.Fprintln(out, "Error:", err)
+		fmt.Fprint(out, "\033[?1049l")
+		return 1
 	}
 
-	fmt.Print("\033[?1049l")
+	fmt.Fprint(out, "\033[?1049l")
+
+	// Print the picked paths once the terminal is restored
+	if opts.print {
+		m := final.(model)
+		if !m.picked {
+			return 1
+		}
+		for _, path := range core.PickedPaths(&m.selector, opts.absolute) {
+			fmt.Print(path + opts.separator)
+		}
+	}
+	return 0
 }
 
 // The application model for Bubble Tea
@@ -112,19 +339,72 @@ type model struct {
 	items    []string
 	selected map[string]bool
 	selector core.Selector
+	watching *core.WatchSession // Watch session whose updates are listened to
+	live     *core.LiveWatch    // Directory watch whose changes are listened to
+	resting  string             // Directory under the cursor waiting to be watched
+	picker   bool               // Picker mode: quitting prints the selected files
+	picked   bool               // The selection was confirmed in picker mode
 }
 
 func (m model) Init() tea.Cmd {
-	// Initialize the position and items if necessary
-	return nil
+	// Listen to the changes of the watched directories
+	return waitForRefresh(m.live)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
 	switch msg := msg.(type) {
+	case refreshMsg:
+		// Files were created, removed or renamed in a watched directory
+		if msg.live == m.selector.Live {
+			core.RefreshAfterChange(&m.selector)
+			m.position = m.selector.Position
+			m.items = m.selector.Filtered
+			cmds = append(cmds, waitForRefresh(msg.live))
+		}
+	case editDoneMsg:
+		// The files may have changed while the editor was open
+		core.RefreshListing(&m.selector)
+		if msg.err != nil {
+			m.selector.StatusMessage = "Editor: " + msg.err.Error()
+			m.selector.StatusTime = time.Now().Unix()
+		}
+		m.position = m.selector.Position
+		m.items = m.selector.Filtered
+		core.SetCurrentSelector(&m.selector)
+	case cursorRestMsg:
+		// Watch the directory under the cursor if the cursor stayed on it
+		m.resting = ""
+		if string(msg) == core.CursorDir(&m.selector) {
+			m.selector.LiveCursor = string(msg)
+		}
+	case watchMsg:
+		// Regenerate the watched bundle here, the export is not safe to run
+		// next to the interface
+		if msg.session == m.selector.Watch {
+			core.RegenerateWatch(&m.selector, msg.changed)
+			cmds = append(cmds, waitForWatch(msg.session))
+		}
+	case commandDoneMsg:
+		// Show the output of the command sent in the background
+		core.ShowCommandOutput(&m.selector, core.CommandOutput(msg))
+		core.SetCurrentSelector(&m.selector)
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "ctrl+c", "q":
+		case "ctrl+c":
 			return m, tea.Quit
+		case "q":
+			// In dialogs "q" is typed or closes the pane
+			if !m.selector.Capturing() {
+				m.picked = m.picker
+				return m, tea.Quit
+			}
+		case "enter":
+			// In picker mode enter confirms the selection, "l" enters directories
+			if m.picker && !m.selector.Capturing() {
+				m.picked = true
+				return m, tea.Quit
+			}
 		}
 		// The key handling is done in input.go.
 		oldPosition := m.position
@@ -142,11 +422,112 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if oldPosition != m.position && m.selector.ActivePanel == 1 {
 			m.selector.UpdateFilesForCurrentDirectory()
 		}
+
+		// Suspend the interface while the file is edited in the terminal
+		if path := m.selector.PendingEdit; path != "" {
+			m.selector.PendingEdit = ""
+			core.SetCurrentSelector(&m.selector)
+			return m, tea.ExecProcess(core.EditorCommand(path), func(err error) tea.Msg {
+				return editDoneMsg{err: err}
+			})
+		}
+
+		// Listen to the updates of a new watch session
+		if m.selector.Watch != nil && m.selector.Watch != m.watching {
+			m.watching = m.selector.Watch
+			cmds = append(cmds, waitForWatch(m.watching))
+		}
+
+		// Run the command chosen in the menu without blocking the interface
+		if pending := m.selector.PendingCommand; pending != nil {
+			m.selector.PendingCommand = nil
+			cmds = append(cmds, func() tea.Msg {
+				return commandDoneMsg(core.RunCommand(*pending))
+			})
+		}
+
+		// Apply a refresh that had to wait for a search or a dialog
+		if m.selector.RefreshPending {
+			core.RefreshAfterChange(&m.selector)
+			m.position = m.selector.Position
+			m.items = m.selector.Filtered
+		}
 	}
-	return m, nil
+
+	// The directory under the cursor is watched once the cursor rests on it,
+	// moving through the listing does not restart the watch at every key
+	if dir := core.CursorDir(&m.selector); dir != m.selector.LiveCursor && dir != m.resting {
+		m.resting = dir
+		cmds = append(cmds, tea.Tick(cursorRestDelay, func(time.Time) tea.Msg {
+			return cursorRestMsg(dir)
+		}))
+	}
+
+	// Keep watching the directories shown in the panels and the selection
+	core.UpdateLiveWatch(&m.selector)
+	if m.selector.Live != m.live {
+		m.live = m.selector.Live
+		cmds = append(cmds, waitForRefresh(m.live))
+	}
+	core.SetCurrentSelector(&m.selector)
+	return m, tea.Batch(cmds...)
+}
+
+// Message sent when a watched directory changes
+type refreshMsg struct {
+	live *core.LiveWatch
 }
 
+// Wait for the next change of the watched directories, until the watch is replaced
+func waitForRefresh(live *core.LiveWatch) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-live.Changes:
+			return refreshMsg{live: live}
+		case <-live.Done():
+			return nil
+		}
+	}
+}
+
+// Message sent when the cursor may have rested on a directory
+type cursorRestMsg string
+
+// Time the cursor stays on a directory before it is watched
+const cursorRestDelay = 300 * time.Millisecond
+
+// Message sent when the terminal editor exits
+type editDoneMsg struct {
+	err error
+}
+
+// Message sent when the files of the watched bundle change
+type watchMsg struct {
+	session *core.WatchSession
+	changed int
+}
+
+// Wait for the next changes of a watch session, until it is stopped
+func waitForWatch(session *core.WatchSession) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case changed := <-session.Changes:
+			return watchMsg{session: session, changed: changed}
+		case <-session.Done():
+			return nil
+		}
+	}
+}
+
+// Message sent when a command started from the menu finishes
+type commandDoneMsg core.CommandOutput
+
 func (m model) View() string {
+	// The result pane of a command covers the panels
+	if m.selector.OutputMode {
+		return core.DrawOutputPane(&m.selector)
+	}
+
 	// Get the directory elements and the position
 	dir := m.selector.Directory
 	items := m.selector.Filtered
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"catselector/config"
+	"catselector/export"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Write the files of one or more bundles (e.g. all the parts of a split
+// bundle) back into a directory
+func runUnpack(args []string) int {
+	flags := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	into := flags.String("into", ".", "directory where the files are written")
+	dryRun := flags.Bool("dry-run", false, "show the changes as a diff without writing")
+	force := flags.Bool("force", false, "also write truncated, minified or redacted files")
+	separator := flags.String("separator", config.Get().LineNumbers.Separator, "separator of the line numbers to remove")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catsel unpack <bundle>... [--into dir] [--dry-run] [--force]")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before or after the bundles
+	var bundles []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 2
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		bundles = append(bundles, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(bundles) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var text strings.Builder
+	for _, bundle := range bundles {
+		var data []byte
+		var err error
+		if bundle == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(bundle)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		text.Write(data)
+	}
+
+	files, err := export.ParseBundle(text.String(), *separator)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	status := 0
+	written, unchanged := 0, 0
+	for _, file := range files {
+		path, err := export.SafePath(*into, file.Name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Refused:", err)
+			status = 1
+			continue
+		}
+		if file.Lossy() && !*force {
+			fmt.Fprintf(os.Stderr, "Skipped: %s is incomplete (%s), use --force to write it\n", file.Name, strings.Join(file.Notes, "; "))
+			continue
+		}
+
+		old, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			status = 1
+			continue
+		}
+		// Bundles written before the final newline was noted add one to every file
+		if err == nil && (string(old) == file.Content || string(old)+"\n" == file.Content) {
+			unchanged++
+			continue
+		}
+
+		if *dryRun {
+			fmt.Print(export.Diff(file.Name, string(old), file.Content))
+			written++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			status = 1
+			continue
+		}
+		if err := os.WriteFile(path, []byte(file.Content), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			status = 1
+			continue
+		}
+		written++
+	}
+
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%d files would change, %d unchanged\n", written, unchanged)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d files written, %d unchanged\n", written, unchanged)
+	}
+	return status
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"catselector/config"
+	"catselector/core"
+	"catselector/export"
+	"catselector/watch"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Regenerate a bundle every time the watched files change, until interrupted
+func runWatch(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	setName := flags.String("set", "", "name of the selection, used in the output name ({set}) and the manifest")
+	output := flags.String("output", "", "file kept up to date (default: the configured output name)")
+	format := flags.String("format", export.FormatText, "export format: text or html")
+	poll := flags.Bool("poll", false, "poll the files instead of using inotify")
+	debounce := flags.Int("debounce", config.Get().WatchDebounce, "quiet time in milliseconds before regenerating")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catsel watch <path>... [--set name] [--output file] [--format text|html] [--poll]")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before or after the paths
+	var paths []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 2
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) == 0 {
+		flags.Usage()
+		return 2
+	}
+	if *format != export.FormatText && *format != export.FormatHTML {
+		fmt.Fprintln(os.Stderr, "Error: watch supports the text and html formats")
+		return 2
+	}
+
+	baseDir := core.GetCurrentDirectory()
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(baseDir, path)
+		}
+	}
+	outputFile := *output
+	if outputFile != "" {
+		outputFile, _ = filepath.Abs(outputFile)
+	}
+
+	// The bundle is always a single file, rewritten in place
+	opts := core.ExportOptions()
+	opts.SetName = *setName
+	opts.Chunk = export.ChunkOptions{}
+	generate := func() (export.Result, error) {
+		// The bundle itself is never exported
+		excluded := []string{outputFile}
+		var result export.Result
+		if *format == export.FormatHTML {
+			result = export.GenerateHTMLFile(paths, excluded, true, baseDir, baseDir, opts)
+		} else {
+			result = export.GenerateTextFile(paths, excluded, true, baseDir, baseDir, opts)
+		}
+		if result.Error != nil {
+			return result, result.Error
+		}
+		if len(result.Parts) == 0 {
+			return result, fmt.Errorf("nothing exported")
+		}
+		if outputFile == "" {
+			outputFile = result.Parts[0]
+		}
+		return result, export.ReplaceOutput(result, outputFile)
+	}
+
+	result, err := generate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	// The next versions are written next to the bundle, then renamed over it
+	opts.Output = export.RegenerateOptions(outputFile)
+	watchOpts := watch.Options{
+		Debounce: time.Duration(*debounce) * time.Millisecond,
+		Poll:     *poll,
+		Skip:     func(name string) bool { return !opts.ShowHidden && export.IsHidden(name, opts.HiddenPatterns) },
+		// Writing the bundle in a watched directory must not trigger a new export
+		Ignore: func(path string) bool { return export.IsOutputFile(path, outputFile) },
+	}
+	stop := make(chan struct{})
+	method := watch.Watch(paths, watchOpts, func(changed []string) {
+		result, err := generate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", time.Now().Format("15:04:05"), err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s %d changed, %d files written to %s\n", time.Now().Format("15:04:05"), len(changed), result.Files, outputFile)
+	}, stop)
+	fmt.Fprintf(os.Stderr, "Watching %d paths with %s, %d files written to %s (Ctrl+C to stop)\n", len(paths), method, result.Files, outputFile)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	close(stop)
+	return 0
+}
@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Events that may change the content of the watched files
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// Source reading the events of the kernel
+type inotify struct {
+	fd   int
+	tree *tree
+	mu   sync.Mutex
+	dirs map[int]string // Watched directory of every watch descriptor
+	ch   chan string
+	done chan struct{}
+}
+
+// Start watching the directories of the tree with inotify
+func newInotify(t *tree) (source, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotify{fd: fd, tree: t, dirs: make(map[int]string), ch: make(chan string), done: make(chan struct{})}
+	for _, dir := range t.watchedDirs() {
+		if err := w.add(dir); err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// Watch a directory
+func (w *inotify) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// Read the events until the source is closed
+func (w *inotify) run() {
+	defer unix.Close(w.fd)
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		// Wait with a timeout so that closing is noticed
+		n, err := unix.Poll(fds, 200)
+		if err != nil && err != unix.EINTR {
+			return
+		}
+		if n <= 0 {
+			continue
+		}
+		n, err = unix.Read(w.fd, buf)
+		if err != nil || n <= 0 {
+			continue
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			w.mu.Lock()
+			dir, ok := w.dirs[int(event.Wd)]
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(event.Wd))
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			path := dir
+			if name := string(bytes.TrimRight(nameBytes, "\x00")); name != "" {
+				path = filepath.Join(dir, name)
+			}
+
+			// New directories inside a watched tree are watched too
+			if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && w.tree.relevant(path) {
+				for _, sub := range w.tree.subdirs(path) {
+					w.add(sub)
+				}
+			}
+
+			if !w.tree.relevant(path) {
+				continue
+			}
+			select {
+			case w.ch <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *inotify) events() <-chan string {
+	return w.ch
+}
+
+func (w *inotify) close() {
+	close(w.done)
+}
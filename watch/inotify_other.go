@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// Systems without inotify always poll
+func newInotify(t *tree) (source, error) {
+	return nil, errors.New("inotify is only available on Linux")
+}
@@ -0,0 +1,109 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State of a file compared between scans
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Source scanning the watched paths at a fixed interval
+type poller struct {
+	ch   chan string
+	done chan struct{}
+}
+
+// Start polling the tree
+func newPoller(t *tree, interval time.Duration) *poller {
+	p := &poller{ch: make(chan string), done: make(chan struct{})}
+	go func() {
+		previous := scan(t)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+			current := scan(t)
+			for path, state := range current {
+				if old, ok := previous[path]; !ok || old != state {
+					if !p.send(path) {
+						return
+					}
+				}
+			}
+			for path := range previous {
+				if _, ok := current[path]; !ok {
+					if !p.send(path) {
+						return
+					}
+				}
+			}
+			previous = current
+		}
+	}()
+	return p
+}
+
+// Send an event, false if the poller was closed
+func (p *poller) send(path string) bool {
+	select {
+	case p.ch <- path:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func (p *poller) events() <-chan string {
+	return p.ch
+}
+
+func (p *poller) close() {
+	close(p.done)
+}
+
+// Get the state of all the watched files
+func scan(t *tree) map[string]fileState {
+	states := make(map[string]fileState)
+	for file := range t.files {
+		if info, err := os.Stat(file); err == nil {
+			states[file] = fileState{info.Size(), info.ModTime()}
+		}
+	}
+	for _, root := range t.dirs {
+		// Only the entries of the directory, including the subdirectories
+		if t.shallow {
+			entries, _ := os.ReadDir(root)
+			for _, entry := range entries {
+				if info, err := entry.Info(); err == nil && !t.skip(entry.Name()) {
+					states[filepath.Join(root, entry.Name())] = fileState{info.Size(), info.ModTime()}
+				}
+			}
+			continue
+		}
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if path != root && t.skip(info.Name()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				states[path] = fileState{info.Size(), info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return states
+}
@@ -0,0 +1,175 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options controls how the paths are watched
+type Options struct {
+	Debounce time.Duration          // Quiet time after the last change before reporting
+	Interval time.Duration          // Interval between scans when polling
+	Skip     func(name string) bool // Entries below the watched directories that are not watched (e.g. hidden)
+	Ignore   func(path string) bool // Paths whose changes are not reported (e.g. the output file)
+	Poll     bool                   // Always poll, even if inotify is available
+	Shallow  bool                   // Watch only the entries at the top level of the directories
+}
+
+// Source of change events
+type source interface {
+	events() <-chan string
+	close()
+}
+
+// Watch reports the changes of the files below the given paths until stop
+// is closed. Directories are watched recursively. The changes are reported
+// together once no change happened during the debounce time. It returns
+// the method used, "inotify" or "polling".
+func Watch(paths []string, opts Options, onChange func(changed []string), stop <-chan struct{}) string {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 300 * time.Millisecond
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Skip == nil {
+		opts.Skip = func(string) bool { return false }
+	}
+	if opts.Ignore == nil {
+		opts.Ignore = func(string) bool { return false }
+	}
+
+	t := newTree(paths, opts)
+	var src source
+	method := "polling"
+	if !opts.Poll {
+		if s, err := newInotify(t); err == nil {
+			src, method = s, "inotify"
+		}
+	}
+	if src == nil {
+		src = newPoller(t, opts.Interval)
+	}
+
+	go func() {
+		defer src.close()
+		pending := make(map[string]bool)
+		timer := time.NewTimer(opts.Debounce)
+		timer.Stop()
+		for {
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case path := <-src.events():
+				if opts.Ignore(path) {
+					continue
+				}
+				pending[path] = true
+				timer.Reset(opts.Debounce)
+			case <-timer.C:
+				changed := make([]string, 0, len(pending))
+				for path := range pending {
+					changed = append(changed, path)
+				}
+				sort.Strings(changed)
+				pending = make(map[string]bool)
+				onChange(changed)
+			}
+		}
+	}()
+	return method
+}
+
+// Watched paths: directories watched recursively and single files
+type tree struct {
+	dirs    []string        // Directories, watched recursively unless shallow
+	files   map[string]bool // Files watched on their own
+	skip    func(name string) bool
+	shallow bool
+}
+
+// Build the tree of the watched paths
+func newTree(paths []string, opts Options) *tree {
+	t := &tree{files: make(map[string]bool), skip: opts.Skip, shallow: opts.Shallow}
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			t.dirs = append(t.dirs, path)
+		} else {
+			t.files[path] = true
+		}
+	}
+	return t
+}
+
+// Check if a change of a path must be reported
+func (t *tree) relevant(path string) bool {
+	if t.files[path] {
+		return true
+	}
+	for _, dir := range t.dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if t.shallow && strings.ContainsRune(rel, filepath.Separator) {
+			continue
+		}
+		// Skip the changes inside hidden entries
+		for p := rel; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if t.skip(filepath.Base(p)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Directories to watch: the watched directories with their subdirectories
+// and the parents of the single files (editors often replace files)
+func (t *tree) watchedDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, root := range t.dirs {
+		for _, dir := range t.subdirs(root) {
+			add(dir)
+		}
+	}
+	for file := range t.files {
+		add(filepath.Dir(file))
+	}
+	return dirs
+}
+
+// List a directory and its subdirectories, without the skipped ones
+func (t *tree) subdirs(root string) []string {
+	if t.shallow {
+		return []string{root}
+	}
+	var dirs []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path != root && t.skip(info.Name()) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs
+}